@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mermonia/peridot/internal/appcontext"
+	"github.com/mermonia/peridot/internal/crypt"
+	"github.com/mermonia/peridot/internal/logger"
+	"github.com/urfave/cli/v3"
+)
+
+type DecryptCommandConfig struct {
+	File    string
+	Output  string
+	Verbose bool
+	Quiet   bool
+}
+
+var decryptCommandDescription string = `
+Decrypts a file encrypted with 'peridot encrypt' (or age itself) using
+the identity set in the [encryption] section of peridot.toml, and prints
+its contents to stdout.
+
+With --output, the contents are written to the given file instead, which
+is created only readable by its owner. Remember not to commit it.
+`
+
+var DecryptCommand cli.Command = cli.Command{
+	Name:        "decrypt",
+	Usage:       "decrypt an encrypted module file",
+	ArgsUsage:   "<file>",
+	Description: decryptCommandDescription,
+	Arguments: []cli.Argument{
+		&cli.StringArg{
+			Name:  "file",
+			Value: "",
+		},
+	},
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:      "output",
+			Aliases:   []string{"o"},
+			Value:     "",
+			Usage:     "write the decrypted contents to a file instead of stdout",
+			TakesFile: true,
+		},
+	},
+	MutuallyExclusiveFlags: []cli.MutuallyExclusiveFlags{
+		{
+			Required: false,
+			Flags: [][]cli.Flag{
+				{
+					&cli.BoolFlag{
+						Name:    "verbose",
+						Aliases: []string{"v"},
+						Value:   false,
+						Usage:   "show verbose debug info",
+					},
+				},
+				{
+					&cli.BoolFlag{
+						Name:    "quiet",
+						Aliases: []string{"q"},
+						Value:   false,
+						Usage:   "supress most logging output",
+					},
+				},
+			},
+		},
+	},
+	Action: func(ctx context.Context, c *cli.Command) error {
+		appCtx := appcontext.New()
+		cmdCfg := &DecryptCommandConfig{
+			File:    c.StringArg("file"),
+			Output:  c.String("output"),
+			Verbose: c.Bool("verbose"),
+			Quiet:   c.Bool("quiet"),
+		}
+
+		return ExecuteDecrypt(cmdCfg, appCtx)
+	},
+}
+
+func ExecuteDecrypt(cmdCfg *DecryptCommandConfig, appCtx *appcontext.Context) error {
+	if err := logger.InitFileLogging(appCtx.DotfilesDir); err != nil {
+		return fmt.Errorf("could not init file logging: %w", err)
+	}
+	defer logger.CloseDefaultLogFile()
+	logger.SetVerboseMode(cmdCfg.Verbose)
+	logger.SetQuietMode(cmdCfg.Quiet)
+
+	if cmdCfg.File == "" {
+		return fmt.Errorf("no file to decrypt was specified")
+	}
+
+	keys, err := crypt.Load(appCtx.DotfilesDir)
+	if err != nil {
+		return fmt.Errorf("could not load encryption keys: %w", err)
+	}
+
+	if cmdCfg.Output != "" {
+		if err := keys.DecryptFile(cmdCfg.File, cmdCfg.Output); err != nil {
+			return err
+		}
+
+		logger.Info("Successfully decrypted file", "file", cmdCfg.Output)
+		return nil
+	}
+
+	// Nothing is printed unless decryption succeeds
+	var decrypted bytes.Buffer
+	if err := decryptToWriter(keys, cmdCfg.File, &decrypted); err != nil {
+		return err
+	}
+
+	_, err = decrypted.WriteTo(os.Stdout)
+	return err
+}
+
+func decryptToWriter(keys *crypt.Keys, path string, out io.Writer) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open file: %w", err)
+	}
+	defer in.Close()
+
+	return keys.Decrypt(out, in)
+}
@@ -7,16 +7,22 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 	"time"
 
+	"github.com/mermonia/peridot/internal/alternate"
 	"github.com/mermonia/peridot/internal/appcontext"
+	"github.com/mermonia/peridot/internal/crypt"
 	"github.com/mermonia/peridot/internal/hash"
 	"github.com/mermonia/peridot/internal/logger"
 	"github.com/mermonia/peridot/internal/module"
 	"github.com/mermonia/peridot/internal/paths"
+	"github.com/mermonia/peridot/internal/secrets"
 	"github.com/mermonia/peridot/internal/state"
+	"github.com/mermonia/peridot/internal/sysinfo"
 	"github.com/mermonia/peridot/internal/templating"
 	"github.com/mermonia/peridot/internal/utils"
+	"github.com/mermonia/peridot/internal/vars"
 	"github.com/urfave/cli/v3"
 )
 
@@ -24,8 +30,10 @@ type DeployCommandConfig struct {
 	Simulate   bool
 	Overwrite  bool
 	Adopt      bool
+	Encrypt    bool
 	Dotreplace bool
 	Root       string
+	Variables  []string
 	ModuleName string
 	Verbose    bool
 	Quiet      bool
@@ -52,6 +60,27 @@ whose structure mimics that of the DOTFILES_DIR itself. For example,
 deploying a file stored as "DOTFILES_DIR/kitty/.config/kitty/kitty.conf":
 	- Creates an intermediate file: "DOTFILES_DIR/.peridot/kitty/.config/kitty/kitty.conf"
 	- Creates a symlink pointing to the intermediate file at ROOT/.config/kitty/kitty.conf
+
+Machine-specific variants of a file can be provided as alternates, by
+appending "##" and a comma-separated list of conditions to its name:
+	- monitors.conf##hostname.laptop
+	- gitconfig##os.darwin,class.work
+	- gitconfig##default
+
+Supported conditions are arch, os, distro, class, hostname and user (or
+their initials). Classes are read from the PERIDOT_CLASS environment
+variable. Among the alternates whose conditions are all met, the most
+specific one is deployed under the base name (e.g. monitors.conf).
+
+Files ending in ".age" (e.g. "ssh/config.age", or "ssh/config.age##class.work"
+for an alternate) are encrypted with age, and deployed decrypted under the
+name without the suffix. Their intermediate files are only readable by
+their owner. The key used to decrypt them is configured in peridot.toml:
+	[encryption]
+	identity = "~/.config/peridot/key.txt"
+
+Existing files can be imported encrypted with --adopt --encrypt. See
+'peridot encrypt --help' for more details.
 `
 
 var DeployCommand cli.Command = cli.Command{
@@ -79,9 +108,19 @@ var DeployCommand cli.Command = cli.Command{
 			Aliases: []string{"r"},
 			Value:   "",
 			Usage: "specify the root path to which the module dir's structure should\n" +
-				"be deployed",
+				"be deployed (may reference $VAR, ${VAR} or ${VAR:-default})",
 			TakesFile: true,
 		},
+		&cli.StringSliceFlag{
+			Name:  "var",
+			Usage: "override a template variable, as key=value (can be repeated)",
+		},
+		&cli.BoolFlag{
+			Name:    "encrypt",
+			Aliases: []string{"e"},
+			Value:   false,
+			Usage:   "encrypt the files imported with --adopt into the module",
+		},
 	},
 	MutuallyExclusiveFlags: []cli.MutuallyExclusiveFlags{
 		{
@@ -93,7 +132,7 @@ var DeployCommand cli.Command = cli.Command{
 						Aliases: []string{"O"},
 						Value:   false,
 						Usage: "forcefully replaces existing files in the filesystem by removing\n" +
-							"them and creating the symlink",
+							"them and creating the symlink, discarding any edits made to deployed files",
 					},
 				},
 				{
@@ -141,8 +180,10 @@ var DeployCommand cli.Command = cli.Command{
 			Simulate:   c.Bool("simulate"),
 			Overwrite:  c.Bool("overwrite"),
 			Adopt:      c.Bool("adopt"),
+			Encrypt:    c.Bool("encrypt"),
 			Dotreplace: c.Bool("dotreplace"),
 			Root:       c.String("root"),
+			Variables:  c.StringSlice("var"),
 			ModuleName: filepath.Clean(c.StringArg("moduleName")),
 			Verbose:    c.Bool("verbose"),
 			Quiet:      c.Bool("quiet"),
@@ -163,6 +204,18 @@ func ExecuteDeploy(cmdCfg *DeployCommandConfig, appCtx *appcontext.Context) erro
 	dotfilesDir := appCtx.DotfilesDir
 	moduleName := cmdCfg.ModuleName
 
+	if cmdCfg.Encrypt && !cmdCfg.Adopt {
+		return fmt.Errorf("the encrypt option can only be used along with adopt")
+	}
+
+	if cmdCfg.Root != "" {
+		root, err := resolveRootFlag(cmdCfg.Root)
+		if err != nil {
+			return fmt.Errorf("could not resolve root %s: %w", cmdCfg.Root, err)
+		}
+		cmdCfg.Root = root
+	}
+
 	st, err := state.LoadState(dotfilesDir)
 	if err != nil {
 		return fmt.Errorf("could not load state: %w", err)
@@ -182,17 +235,52 @@ func ExecuteDeploy(cmdCfg *DeployCommandConfig, appCtx *appcontext.Context) erro
 		return fmt.Errorf("could not load module %s: %w", moduleName, err)
 	}
 
-	if !mod.ShouldDeploy(st) {
+	overrides, err := vars.ParseAssignments(cmdCfg.Variables)
+	if err != nil {
+		return err
+	}
+
+	if _, err := mod.ResolveVariables(dotfilesDir, sysinfo.Current().Hostname, overrides); err != nil {
+		return err
+	}
+
+	if err := mod.CheckDeployable(st); err != nil {
 		return fmt.Errorf("the module %s could not be deployed: %w", moduleName, err)
 	}
 
-	filesToDeploy := getFilesToDeploy(dotfilesDir, mod)
+	root := mod.Config.Root
+	if cmdCfg.Root != "" {
+		root = cmdCfg.Root
+	}
+
+	templateOpts, err := newTemplateOptions(dotfilesDir, mod, root)
+	if err != nil {
+		return err
+	}
+
+	filesToDeploy, err := getFilesToDeploy(dotfilesDir, mod, templateOpts)
+	if err != nil {
+		return fmt.Errorf("could not get files to deploy: %w", err)
+	}
+
+	if cmdCfg.Dotreplace {
+		if err := checkConflictingPaths(filesToDeploy, true); err != nil {
+			return fmt.Errorf("could not get files to deploy: %w", err)
+		}
+	}
+
+	// Edits made to deployed files would be silently lost otherwise
+	if modified := mod.State.ModifiedTargets(); len(modified) > 0 && !cmdCfg.Overwrite && !cmdCfg.Simulate {
+		return fmt.Errorf("the deployed files %s were modified since they were deployed, run 'peridot pull %s' "+
+			"to keep the changes or deploy with --overwrite to discard them", strings.Join(modified, ", "), moduleName)
+	}
+
 	if cmdCfg.Simulate {
 		if err := simulateDeployment(dotfilesDir, mod, filesToDeploy, cmdCfg); err != nil {
 			return fmt.Errorf("could not simulate deployment of module %s, %w", moduleName, err)
 		}
 	} else {
-		if err := deployFiles(dotfilesDir, mod, filesToDeploy, cmdCfg); err != nil {
+		if err := deployFiles(dotfilesDir, mod, filesToDeploy, templateOpts, cmdCfg); err != nil {
 			return fmt.Errorf("could not deploy module %s: %w", moduleName, err)
 		}
 	}
@@ -205,38 +293,172 @@ func ExecuteDeploy(cmdCfg *DeployCommandConfig, appCtx *appcontext.Context) erro
 	return nil
 }
 
-func getFilesToDeploy(dotfilesDir string, mod *module.Module) []string {
+// resolveRootFlag resolves the --root flag just like the root field of
+// module.toml, except that relative paths are relative to the working dir.
+func resolveRootFlag(root string) (string, error) {
+	expanded, err := paths.ExpandEnv(root)
+	if err != nil {
+		return "", err
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("could not get working dir: %w", err)
+	}
+
+	return paths.ResolvePath(expanded, cwd)
+}
+
+// moduleFile is a file of a module that should be deployed. Source is the
+// actual file in the module dir, while Path is the path (also inside the
+// module dir) it is deployed as, which differs from Source for alternates,
+// encrypted files and templates in suffix mode.
+type moduleFile struct {
+	Source    string
+	Path      string
+	Template  bool
+	Encrypted bool
+}
+
+// getFilesToDeploy returns the files of the module that would be deployed
+// with the given template options. They decide the alternates that are
+// selected (through the system they are rendered for) and the paths of files
+// with templated names. It fails if several files would be deployed at the
+// same path, except for conflicts caused by --dotreplace, which only deploy
+// knows about.
+func getFilesToDeploy(dotfilesDir string, mod *module.Module, templateOpts *templating.Options) ([]moduleFile, error) {
 	moduleDir := paths.ModuleDir(dotfilesDir, mod.Name)
-	files := []string{}
+	candidates := []string{}
+
+	system := templateOpts.System
+	if system == nil {
+		system = sysinfo.Current()
+	}
+
+	err := filepath.WalkDir(moduleDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
 
-	// TODO: Handle WalkDir error
-	filepath.WalkDir(moduleDir, func(path string, d fs.DirEntry, err error) error {
 		if d.IsDir() {
+			if path == paths.PartialsDir(dotfilesDir, mod.Name) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
-		if slices.Contains(mod.Config.Ignore, filepath.Base(path)) {
+		if slices.Contains(mod.Config.Ignore, filepath.Base(path)) || slices.Contains(mod.Config.VarsFiles, path) {
 			return nil
 		}
 
-		files = append(files, path)
+		candidates = append(candidates, path)
 		return nil
 	})
+	if err != nil {
+		return nil, fmt.Errorf("could not walk module dir: %w", err)
+	}
+
+	selected, err := alternate.Select(candidates, system)
+	if err != nil {
+		return nil, fmt.Errorf("could not select alternates: %w", err)
+	}
 
-	return files
+	files := make([]moduleFile, 0, len(selected))
+	for path, source := range selected {
+		rel, err := filepath.Rel(moduleDir, path)
+		if err != nil {
+			return nil, fmt.Errorf("could not relativize path: %w", err)
+		}
+
+		file := moduleFile{
+			Source:   source,
+			Path:     mod.Config.Template.TargetPath(path, rel),
+			Template: mod.Config.Template.IsTemplate(rel),
+		}
+
+		// Encrypted files are decrypted as they are, without rendering
+		if crypt.IsEncrypted(path) {
+			file = moduleFile{
+				Source:    source,
+				Path:      crypt.DecryptedPath(path),
+				Encrypted: true,
+			}
+		}
+
+		if mod.Config.Template.TemplatedNames() {
+			if file.Path, err = renderModulePath(moduleDir, file.Path, templateOpts); err != nil {
+				return nil, err
+			}
+		}
+
+		files = append(files, file)
+	}
+
+	slices.SortFunc(files, func(a, b moduleFile) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	if err := checkConflictingPaths(files, false); err != nil {
+		return nil, err
+	}
+
+	return files, nil
 }
 
-func deployFiles(dotfilesDir string, mod *module.Module, files []string, cmdCfg *DeployCommandConfig) error {
+// checkConflictingPaths fails if several files would be deployed at the same
+// path. Templated names, alternates and suffixes might make different files
+// end up at the same path, and so might --dotreplace, which must be caught
+// before touching any.
+func checkConflictingPaths(files []moduleFile, dotreplace bool) error {
+	sources := map[string]string{}
+	for _, file := range files {
+		path := file.Path
+		if dotreplace {
+			path = paths.GetDotreplacedPath(path)
+		}
+
+		if source, exists := sources[path]; exists {
+			return fmt.Errorf("%s and %s would both be deployed as %s", source, file.Source, path)
+		}
+		sources[path] = file.Source
+	}
+
+	return nil
+}
+
+// renderModulePath renders the templated names in a path inside the module dir.
+func renderModulePath(moduleDir, path string, templateOpts *templating.Options) (string, error) {
+	rel, err := filepath.Rel(moduleDir, path)
+	if err != nil {
+		return "", fmt.Errorf("could not relativize path: %w", err)
+	}
+
+	rendered, err := templating.RenderPath(rel, templateOpts)
+	if err != nil {
+		return "", fmt.Errorf("could not render the path of %s: %w", rel, err)
+	}
+
+	return filepath.Join(moduleDir, rendered), nil
+}
+
+func deployFiles(dotfilesDir string, mod *module.Module, files []moduleFile, templateOpts *templating.Options, cmdCfg *DeployCommandConfig) error {
 	if err := utils.ExecHook(mod.Config.Hooks.PreDeploy); err != nil {
 		return fmt.Errorf("could not execute the pre-deploy hook: %w", err)
 	}
 
-	root := mod.Config.Root
-	if cmdCfg.Root != "" {
-		root = cmdCfg.Root
+	root := templateOpts.Root
+	deployedAt := time.Now()
+
+	var err error
+	var keys *crypt.Keys
+	if cmdCfg.Encrypt || slices.ContainsFunc(files, func(f moduleFile) bool { return f.Encrypted }) {
+		if keys, err = crypt.Load(dotfilesDir); err != nil {
+			return fmt.Errorf("could not load encryption keys: %w", err)
+		}
 	}
 
-	for _, path := range files {
+	for _, file := range files {
+		path := file.Path
 		if cmdCfg.Dotreplace {
 			path = paths.GetDotreplacedPath(path)
 		}
@@ -251,12 +473,13 @@ func deployFiles(dotfilesDir string, mod *module.Module, files []string, cmdCfg
 			return fmt.Errorf("could not get potential symlink path: %w", err)
 		}
 
-		if err := resolveSymlinkCollision(mod, path, symlinkPath, cmdCfg.Adopt, cmdCfg.Overwrite); err != nil {
+		if err := resolveSymlinkCollision(mod, &file, symlinkPath, cmdCfg, keys); err != nil {
 			return err
 		}
 
-		if err := templating.CreateRenderedFile(path, renderedFilePath, mod.Config.TemplateVariables); err != nil {
-			return fmt.Errorf("could not render template: %w", err)
+		result, err := createIntermediateFile(file, renderedFilePath, symlinkPath, templateOpts, keys)
+		if err != nil {
+			return err
 		}
 
 		if err := os.Remove(symlinkPath); err != nil && !os.IsNotExist(err) {
@@ -267,21 +490,73 @@ func deployFiles(dotfilesDir string, mod *module.Module, files []string, cmdCfg
 			return err
 		}
 
-		fileHash, err := hash.HashFile(path)
+		fileHash, err := hash.HashFile(file.Source)
 		if err != nil {
 			return err
 		}
 
-		mod.State.Files[path] = &state.Entry{
+		// Every template depends on the module's vars files, as there is
+		// no telling which of their variables it uses
+		fileDependencies := result.Dependencies
+		if file.Template {
+			fileDependencies = append(fileDependencies, mod.Config.VarsFiles...)
+		}
+
+		dependencies := make(map[string]string, len(fileDependencies))
+		for _, dep := range fileDependencies {
+			if dependencies[dep], err = hash.HashFile(dep); err != nil {
+				return err
+			}
+		}
+
+		// A different alternate of the same file might have been deployed
+		// before, in which case its entry is replaced by the new one.
+		for source, entry := range mod.State.Files {
+			if source != file.Source && entry.SymlinkPath == symlinkPath {
+				delete(mod.State.Files, source)
+			}
+		}
+
+		// The file might have been deployed somewhere else before, if its
+		// name is templated, in which case the old symlink is left dangling
+		if previous := mod.State.Files[file.Source]; previous != nil && previous.SymlinkPath != symlinkPath {
+			if info, err := os.Lstat(previous.SymlinkPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				if err := os.Remove(previous.SymlinkPath); err != nil {
+					return fmt.Errorf("could not remove previous symlink: %w", err)
+				}
+			}
+		}
+
+		intermediateHash, err := hash.HashFile(renderedFilePath)
+		if err != nil {
+			return err
+		}
+
+		variablesHash := ""
+		if file.Template {
+			if variablesHash, err = hash.HashValue(templateOpts.Variables); err != nil {
+				return fmt.Errorf("could not hash the variables: %w", err)
+			}
+		}
+
+		mod.State.Files[file.Source] = &state.Entry{
 			Status:           state.Synced,
 			SourceHash:       fileHash,
 			IntermediatePath: renderedFilePath,
 			SymlinkPath:      symlinkPath,
+			Template:         file.Template,
+			Dependencies:     dependencies,
+			IntermediateHash: intermediateHash,
+			DeployedAt:       deployedAt,
+			VariablesHash:    variablesHash,
+			Root:             root,
+			RootOverridden:   cmdCfg.Root != "",
+			Dotreplace:       cmdCfg.Dotreplace,
 		}
 	}
 
 	mod.State.Status = state.Synced
-	mod.State.DeployedAt = time.Now()
+	mod.State.DeployedAt = deployedAt
 
 	if err := utils.ExecHook(mod.Config.Hooks.PostDeploy); err != nil {
 		return fmt.Errorf("could not execute the post-deploy hook: %w", err)
@@ -290,7 +565,62 @@ func deployFiles(dotfilesDir string, mod *module.Module, files []string, cmdCfg
 	return nil
 }
 
-func resolveSymlinkCollision(mod *module.Module, path, symlinkPath string, adopt, overwrite bool) error {
+// createIntermediateFile creates the file the symlink of a module file points
+// to, by either decrypting, rendering or copying it.
+func createIntermediateFile(file moduleFile, renderedFilePath, symlinkPath string,
+	templateOpts *templating.Options, keys *crypt.Keys,
+) (*templating.Result, error) {
+	if file.Encrypted {
+		if err := keys.DecryptFile(file.Source, renderedFilePath); err != nil {
+			return nil, fmt.Errorf("could not decrypt file: %w", err)
+		}
+		return &templating.Result{Dependencies: []string{}}, nil
+	}
+
+	var fileOpts *templating.Options
+	if file.Template {
+		opts := *templateOpts
+		opts.Target = symlinkPath
+		fileOpts = &opts
+	}
+
+	result, err := templating.CreateRenderedFile(file.Source, renderedFilePath, fileOpts)
+	if err != nil {
+		return nil, fmt.Errorf("could not render template: %w", err)
+	}
+
+	return result, nil
+}
+
+// newTemplateOptions returns the options every template of a module is
+// rendered with. The target of each file has to be set separately.
+func newTemplateOptions(dotfilesDir string, mod *module.Module, root string) (*templating.Options, error) {
+	partials, err := templating.FindPartials(paths.SharedPartialsDir(dotfilesDir),
+		paths.PartialsDir(dotfilesDir, mod.Name))
+	if err != nil {
+		return nil, fmt.Errorf("could not find partials: %w", err)
+	}
+
+	secretStore, err := secrets.Load(dotfilesDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not load secret providers: %w", err)
+	}
+
+	return &templating.Options{
+		DotfilesDir: dotfilesDir,
+		Module:      mod.Name,
+		ModuleDir:   paths.ModuleDir(dotfilesDir, mod.Name),
+		Root:        root,
+		Variables:   mod.Variables,
+		LeftDelim:   mod.Config.Template.LeftDelim,
+		RightDelim:  mod.Config.Template.RightDelim,
+		Partials:    partials,
+		Strict:      mod.Config.Template.IsStrict(),
+		Secrets:     secretStore,
+	}, nil
+}
+
+func resolveSymlinkCollision(mod *module.Module, file *moduleFile, symlinkPath string, cmdCfg *DeployCommandConfig, keys *crypt.Keys) error {
 	info, err := os.Lstat(symlinkPath)
 	if err != nil {
 		if !os.IsNotExist(err) {
@@ -298,11 +628,11 @@ func resolveSymlinkCollision(mod *module.Module, path, symlinkPath string, adopt
 		}
 	} else {
 		if info.Mode()&os.ModeSymlink == 0 {
-			if adopt {
-				if err := utils.Copy(symlinkPath, path); err != nil {
-					return fmt.Errorf("could not copy: %w", err)
+			if cmdCfg.Adopt {
+				if err := adoptFile(file, symlinkPath, cmdCfg.Encrypt, keys); err != nil {
+					return err
 				}
-			} else if !overwrite {
+			} else if !cmdCfg.Overwrite {
 				return fmt.Errorf("found non-symlink without adopt or overwrite option at: %s", symlinkPath)
 			}
 		} else if !mod.IsSymlinkManaged(symlinkPath) {
@@ -312,6 +642,34 @@ func resolveSymlinkCollision(mod *module.Module, path, symlinkPath string, adopt
 	return nil
 }
 
+// adoptFile imports the existing file at symlinkPath into the module. If it
+// should be encrypted and the module file was not, the module file is
+// replaced by an encrypted one, and file is updated accordingly.
+func adoptFile(file *moduleFile, symlinkPath string, encrypt bool, keys *crypt.Keys) error {
+	if !encrypt && !file.Encrypted {
+		if err := utils.Copy(symlinkPath, file.Source); err != nil {
+			return fmt.Errorf("could not copy: %w", err)
+		}
+		return nil
+	}
+
+	encryptedPath := crypt.EncryptedPath(file.Source)
+	if err := keys.EncryptFile(symlinkPath, encryptedPath); err != nil {
+		return fmt.Errorf("could not encrypt: %w", err)
+	}
+
+	if encryptedPath != file.Source {
+		if err := os.Remove(file.Source); err != nil {
+			return fmt.Errorf("could not remove unencrypted file: %w", err)
+		}
+	}
+
+	file.Source = encryptedPath
+	file.Encrypted = true
+	file.Template = false
+	return nil
+}
+
 func createSymlink(symlinkPath, targetPath string) error {
 	if err := os.MkdirAll(filepath.Dir(symlinkPath), 0755); err != nil {
 		return fmt.Errorf("could not create parent dirs: %w", err)
@@ -323,7 +681,7 @@ func createSymlink(symlinkPath, targetPath string) error {
 
 	return nil
 }
-func simulateDeployment(dotfilesDir string, mod *module.Module, files []string, cmdCfg *DeployCommandConfig) error {
+func simulateDeployment(dotfilesDir string, mod *module.Module, files []moduleFile, cmdCfg *DeployCommandConfig) error {
 	fmt.Println("\n=== SIMULATION MODE ===")
 	fmt.Println("No changes will be made to the filesystem")
 
@@ -341,11 +699,32 @@ func simulateDeployment(dotfilesDir string, mod *module.Module, files []string,
 
 	var actions, warnings, errors []string
 
-	for _, path := range files {
+	for _, symlinkPath := range mod.State.ModifiedTargets() {
+		if cmdCfg.Overwrite {
+			warnings = append(warnings, fmt.Sprintf("Changes made to %s will be discarded", symlinkPath))
+		} else {
+			errors = append(errors, fmt.Sprintf("%s was modified since it was deployed (use 'peridot pull' or --overwrite)", symlinkPath))
+		}
+	}
+
+	for _, file := range files {
+		path := file.Path
 		if cmdCfg.Dotreplace {
 			path = paths.GetDotreplacedPath(path)
 		}
 
+		if file.Source != file.Path {
+			actions = append(actions, fmt.Sprintf("SELECT: %s as %s", filepath.Base(file.Source), filepath.Base(file.Path)))
+		}
+
+		if file.Template {
+			actions = append(actions, fmt.Sprintf("RENDER: %s", file.Source))
+		}
+
+		if file.Encrypted {
+			actions = append(actions, fmt.Sprintf("DECRYPT: %s", file.Source))
+		}
+
 		renderedFilePath, err := paths.RenderedFilePath(path, dotfilesDir)
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("Could not get rendered file path for %s: %v", path, err))
@@ -370,6 +749,8 @@ func simulateDeployment(dotfilesDir string, mod *module.Module, files []string,
 
 		if info.Mode()&os.ModeSymlink == 0 {
 			switch {
+			case cmdCfg.Adopt && (cmdCfg.Encrypt || file.Encrypted):
+				actions = append(actions, fmt.Sprintf("ADOPT: %s (encrypt into module, then symlink)", symlinkPath))
 			case cmdCfg.Adopt:
 				actions = append(actions, fmt.Sprintf("ADOPT: %s (copy to module, then symlink)", symlinkPath))
 			case cmdCfg.Overwrite:
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/mermonia/peridot/internal/appcontext"
+	"github.com/mermonia/peridot/internal/crypt"
+	"github.com/mermonia/peridot/internal/logger"
+	"github.com/urfave/cli/v3"
+)
+
+type EncryptCommandConfig struct {
+	File    string
+	Keep    bool
+	Verbose bool
+	Quiet   bool
+}
+
+var encryptCommandDescription string = `
+Encrypts a file with age, storing it next to the original with the ".age"
+suffix (before any alternate suffix, e.g. "config##class.work" is stored
+as "config.age##class.work"). The unencrypted file is then removed, unless
+--keep is set.
+
+Encrypted files can be committed to public repositories. When deployed,
+they are decrypted into intermediate files only readable by their owner.
+
+Files are encrypted to the recipients set in the [encryption] section of
+peridot.toml or, if there are none, to the public key of its identity:
+	[encryption]
+	identity = "~/.config/peridot/key.txt"
+	recipients = ["age1..."]
+
+An identity can be created with "age-keygen -o ~/.config/peridot/key.txt".
+It must be kept outside of the dotfiles dir, and copied to every machine
+the encrypted files are deployed on.
+
+To edit an encrypted file:
+	peridot decrypt ssh/.ssh/config.age -o ssh/.ssh/config
+	$EDITOR ssh/.ssh/config
+	peridot encrypt ssh/.ssh/config
+`
+
+var EncryptCommand cli.Command = cli.Command{
+	Name:        "encrypt",
+	Usage:       "encrypt a module file",
+	ArgsUsage:   "<file>",
+	Description: encryptCommandDescription,
+	Arguments: []cli.Argument{
+		&cli.StringArg{
+			Name:  "file",
+			Value: "",
+		},
+	},
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "keep",
+			Aliases: []string{"k"},
+			Value:   false,
+			Usage:   "keep the unencrypted file",
+		},
+	},
+	MutuallyExclusiveFlags: []cli.MutuallyExclusiveFlags{
+		{
+			Required: false,
+			Flags: [][]cli.Flag{
+				{
+					&cli.BoolFlag{
+						Name:    "verbose",
+						Aliases: []string{"v"},
+						Value:   false,
+						Usage:   "show verbose debug info",
+					},
+				},
+				{
+					&cli.BoolFlag{
+						Name:    "quiet",
+						Aliases: []string{"q"},
+						Value:   false,
+						Usage:   "supress most logging output",
+					},
+				},
+			},
+		},
+	},
+	Action: func(ctx context.Context, c *cli.Command) error {
+		appCtx := appcontext.New()
+		cmdCfg := &EncryptCommandConfig{
+			File:    c.StringArg("file"),
+			Keep:    c.Bool("keep"),
+			Verbose: c.Bool("verbose"),
+			Quiet:   c.Bool("quiet"),
+		}
+
+		return ExecuteEncrypt(cmdCfg, appCtx)
+	},
+}
+
+func ExecuteEncrypt(cmdCfg *EncryptCommandConfig, appCtx *appcontext.Context) error {
+	if err := logger.InitFileLogging(appCtx.DotfilesDir); err != nil {
+		return fmt.Errorf("could not init file logging: %w", err)
+	}
+	defer logger.CloseDefaultLogFile()
+	logger.SetVerboseMode(cmdCfg.Verbose)
+	logger.SetQuietMode(cmdCfg.Quiet)
+
+	if cmdCfg.File == "" {
+		return fmt.Errorf("no file to encrypt was specified")
+	}
+
+	if crypt.IsEncrypted(cmdCfg.File) {
+		return fmt.Errorf("the file %s is already encrypted", cmdCfg.File)
+	}
+
+	keys, err := crypt.Load(appCtx.DotfilesDir)
+	if err != nil {
+		return fmt.Errorf("could not load encryption keys: %w", err)
+	}
+
+	encryptedPath := crypt.EncryptedPath(cmdCfg.File)
+	if err := keys.EncryptFile(cmdCfg.File, encryptedPath); err != nil {
+		return err
+	}
+
+	if !cmdCfg.Keep {
+		if err := os.Remove(cmdCfg.File); err != nil {
+			return fmt.Errorf("could not remove unencrypted file: %w", err)
+		}
+	}
+
+	logger.Info("Successfully encrypted file", "file", encryptedPath)
+	return nil
+}
@@ -0,0 +1,307 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/mermonia/peridot/internal/appcontext"
+	"github.com/mermonia/peridot/internal/crypt"
+	"github.com/mermonia/peridot/internal/diff"
+	"github.com/mermonia/peridot/internal/hash"
+	"github.com/mermonia/peridot/internal/logger"
+	"github.com/mermonia/peridot/internal/module"
+	"github.com/mermonia/peridot/internal/paths"
+	"github.com/mermonia/peridot/internal/state"
+	"github.com/mermonia/peridot/internal/sysinfo"
+	"github.com/mermonia/peridot/internal/templating"
+	"github.com/mermonia/peridot/internal/utils"
+	"github.com/urfave/cli/v3"
+)
+
+type PullCommandConfig struct {
+	Target  string
+	Verbose bool
+	Quiet   bool
+}
+
+var pullCommandDescription string = `
+Brings the changes made to deployed files back into the dotfiles dir.
+
+Deployed files are symlinks to intermediate files, so editing them
+(with a program that rewrites its own config, for example) leaves the
+files in the module dir untouched, and the next deployment would
+discard those edits. 'peridot status' reports such files as modified
+in the target, and 'peridot deploy' refuses to overwrite them.
+
+The argument can either be the name of a module, which pulls every
+modified file of the module, or the path to a single file, given as
+the deployed file, its intermediate file or the file in the module dir.
+
+For plain files, the edited contents are copied back into the module
+dir. Encrypted files are encrypted again with the configured keys. So
+are templates without any template action, which render to themselves;
+with the default template mode of "all", that is most text files.
+
+Other templates cannot be pulled automatically, as there is no way of
+knowing which parts of the template produced the edited lines.
+Instead, a diff between the current rendering of the template and
+the edited file is printed, so that the changes can be merged into
+the template by hand. Then, run 'peridot deploy <module> --overwrite'.
+The diff of templates that use secrets is never printed, as it would
+reveal them.
+`
+
+var PullCommand cli.Command = cli.Command{
+	Name:        "pull",
+	Aliases:     []string{"p"},
+	Usage:       "bring the changes made to deployed files back into the dotfiles dir",
+	ArgsUsage:   "<module|path>",
+	Description: pullCommandDescription,
+	Arguments: []cli.Argument{
+		&cli.StringArg{
+			Name:  "target",
+			Value: "",
+		},
+	},
+	MutuallyExclusiveFlags: []cli.MutuallyExclusiveFlags{
+		{
+			Required: false,
+			Flags: [][]cli.Flag{
+				{
+					&cli.BoolFlag{
+						Name:    "verbose",
+						Aliases: []string{"v"},
+						Value:   false,
+						Usage:   "show verbose debug info",
+					},
+				},
+				{
+					&cli.BoolFlag{
+						Name:    "quiet",
+						Aliases: []string{"q"},
+						Value:   false,
+						Usage:   "supress most logging output",
+					},
+				},
+			},
+		},
+	},
+	Action: func(ctx context.Context, c *cli.Command) error {
+		appCtx := appcontext.New()
+		cmdCfg := &PullCommandConfig{
+			Target:  c.StringArg("target"),
+			Verbose: c.Bool("verbose"),
+			Quiet:   c.Bool("quiet"),
+		}
+
+		if cmdCfg.Target == "" {
+			return fmt.Errorf("a module or a file must be specified")
+		}
+
+		return ExecutePull(cmdCfg, appCtx)
+	},
+}
+
+func ExecutePull(cmdCfg *PullCommandConfig, appCtx *appcontext.Context) error {
+	if err := logger.InitFileLogging(appCtx.DotfilesDir); err != nil {
+		return fmt.Errorf("could not init file logging: %w", err)
+	}
+	defer logger.CloseDefaultLogFile()
+	logger.SetVerboseMode(cmdCfg.Verbose)
+	logger.SetQuietMode(cmdCfg.Quiet)
+
+	dotfilesDir := appCtx.DotfilesDir
+
+	st, err := state.LoadState(dotfilesDir)
+	if err != nil {
+		return fmt.Errorf("could not load state: %w", err)
+	}
+
+	if err := st.Refresh(dotfilesDir); err != nil {
+		return fmt.Errorf("could not refresh state: %w", err)
+	}
+
+	moduleName, sources, err := findPullTargets(st, cmdCfg.Target)
+	if err != nil {
+		return err
+	}
+
+	if len(sources) == 0 {
+		logger.Info("No deployed file was modified, nothing to pull", "target", cmdCfg.Target)
+		return nil
+	}
+
+	mod, err := module.Load(dotfilesDir, moduleName, st.Modules[moduleName])
+	if err != nil {
+		return fmt.Errorf("could not load module %s: %w", moduleName, err)
+	}
+
+	var keys *crypt.Keys
+	for _, source := range sources {
+		entry := mod.State.Files[source]
+
+		if entry.Template {
+			rendered, result, err := renderPulledTemplate(dotfilesDir, mod, source, entry)
+			if err != nil {
+				return fmt.Errorf("could not render template %s: %w", source, err)
+			}
+
+			// Templates without any action render to themselves, so the
+			// edited file can be copied back like a plain one
+			content, err := os.ReadFile(source)
+			if err != nil {
+				return fmt.Errorf("could not read template %s: %w", source, err)
+			}
+
+			if !bytes.Equal(rendered, content) {
+				if err := printTemplateDiff(mod, source, entry, rendered, result); err != nil {
+					return fmt.Errorf("could not diff template %s: %w", source, err)
+				}
+				continue
+			}
+		}
+
+		switch {
+		// Pulling would discard the changes made to the source otherwise
+		case entry.Status != state.Synced:
+			return fmt.Errorf("both %s and its deployed file %s were modified, merge them by hand",
+				source, entry.SymlinkPath)
+
+		case crypt.IsEncrypted(source):
+			if keys == nil {
+				if keys, err = crypt.Load(dotfilesDir); err != nil {
+					return fmt.Errorf("could not load encryption keys: %w", err)
+				}
+			}
+
+			if err := keys.EncryptFile(entry.IntermediatePath, source); err != nil {
+				return fmt.Errorf("could not encrypt %s: %w", entry.IntermediatePath, err)
+			}
+
+		default:
+			if err := utils.Copy(entry.IntermediatePath, source); err != nil {
+				return fmt.Errorf("could not copy %s: %w", entry.IntermediatePath, err)
+			}
+		}
+
+		if err := markPulled(source, entry); err != nil {
+			return err
+		}
+		logger.Info("Pulled changes", "from", entry.SymlinkPath, "to", source)
+	}
+
+	if err := state.SaveState(st, dotfilesDir); err != nil {
+		return fmt.Errorf("could not save state: %w", err)
+	}
+
+	logger.Info("Successfully executed command!", "command", "pull")
+	return nil
+}
+
+// findPullTargets returns the module and the sources of the modified deployed
+// files the target refers to, which is either a module name or the path to
+// one of its files.
+func findPullTargets(st *state.State, target string) (string, []string, error) {
+	if moduleState := st.Modules[filepath.Clean(target)]; moduleState != nil {
+		sources := []string{}
+		for source, entry := range moduleState.Files {
+			if entry.Drift == state.TargetModified {
+				sources = append(sources, source)
+			}
+		}
+
+		slices.Sort(sources)
+		return filepath.Clean(target), sources, nil
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", nil, fmt.Errorf("could not get working dir: %w", err)
+	}
+
+	path, err := paths.ResolvePath(target, cwd)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for name, moduleState := range st.Modules {
+		for source, entry := range moduleState.Files {
+			if path == source || path == entry.SymlinkPath || path == entry.IntermediatePath {
+				if entry.Drift != state.TargetModified {
+					return name, nil, nil
+				}
+				return name, []string{source}, nil
+			}
+		}
+	}
+
+	return "", nil, fmt.Errorf("%s is neither a managed module nor a deployed file", target)
+}
+
+// markPulled records that the source and the intermediate file of an entry
+// match again, so that neither is reported as changed.
+func markPulled(source string, entry *state.Entry) error {
+	sourceHash, err := hash.HashFile(source)
+	if err != nil {
+		return fmt.Errorf("could not hash file %s: %w", source, err)
+	}
+
+	intermediateHash, err := hash.HashFile(entry.IntermediatePath)
+	if err != nil {
+		return fmt.Errorf("could not hash file %s: %w", entry.IntermediatePath, err)
+	}
+
+	entry.SourceHash = sourceHash
+	entry.IntermediateHash = intermediateHash
+	entry.Drift = state.NoDrift
+	return nil
+}
+
+// renderPulledTemplate renders the source of a deployed template with the
+// current configuration of its module.
+func renderPulledTemplate(dotfilesDir string, mod *module.Module, source string,
+	entry *state.Entry) ([]byte, *templating.Result, error) {
+	if _, err := mod.ResolveVariables(dotfilesDir, sysinfo.Current().Hostname, nil); err != nil {
+		return nil, nil, err
+	}
+
+	opts, err := newTemplateOptions(dotfilesDir, mod, mod.Config.Root)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts.Target = entry.SymlinkPath
+
+	var rendered bytes.Buffer
+	result, err := templating.RenderFile(source, opts, &rendered)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rendered.Bytes(), result, nil
+}
+
+// printTemplateDiff prints the changes made to the deployed file of a
+// template, compared to what the template currently renders to.
+func printTemplateDiff(mod *module.Module, source string, entry *state.Entry,
+	rendered []byte, result *templating.Result) error {
+	// Even the unchanged lines around the edits might reveal secrets
+	if result.Sensitive {
+		logger.Warn("The template uses secrets, so its diff is not shown, compare it with its deployed file by hand",
+			"template", source, "deployed", entry.SymlinkPath)
+	} else {
+		deployed, err := os.ReadFile(entry.IntermediatePath)
+		if err != nil {
+			return fmt.Errorf("could not read deployed file: %w", err)
+		}
+
+		fmt.Print(diff.Unified("rendered "+source, entry.SymlinkPath, string(rendered), string(deployed)))
+	}
+
+	logger.Warn("Templates cannot be pulled automatically, merge the changes into the template by hand, "+
+		"then run 'peridot deploy "+mod.Name+" --overwrite'", "template", source)
+	return nil
+}
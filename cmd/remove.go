@@ -31,6 +31,8 @@ treated specially to avoid leaving broken symlinks in the filesystem:
 	of the template files in the module dir.
 	- Keep in mind that the new files will reflect the current state
 	of the template files, not the state of their last deployment.
+	- If any deployed file was edited since it was deployed, nothing
+	is removed. Run 'peridot pull' first to keep the edits.
 
 After taking care of deployed files, the entire module directory
 will be removed from the dotfiles directory.
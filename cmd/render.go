@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mermonia/peridot/internal/appcontext"
+	"github.com/mermonia/peridot/internal/crypt"
+	"github.com/mermonia/peridot/internal/logger"
+	"github.com/mermonia/peridot/internal/module"
+	"github.com/mermonia/peridot/internal/paths"
+	"github.com/mermonia/peridot/internal/state"
+	"github.com/mermonia/peridot/internal/sysinfo"
+	"github.com/mermonia/peridot/internal/templating"
+	"github.com/mermonia/peridot/internal/utils"
+	"github.com/mermonia/peridot/internal/vars"
+	"github.com/urfave/cli/v3"
+)
+
+type RenderCommandConfig struct {
+	ModuleName string
+	File       string
+	Variables  []string
+	Host       string
+	Verbose    bool
+	Quiet      bool
+}
+
+var renderCommandDescription string = `
+Renders a file of a module and writes the result to stdout, without
+deploying anything. The variables are resolved just like deploy does.
+Encrypted files are decrypted instead.
+
+The file can be specified either relative to the module dir, by the
+path it is deployed at, or as an absolute path to any of those:
+	peridot render kitty .config/kitty/kitty.conf
+	peridot render kitty ~/.config/kitty/kitty.conf
+
+The --host flag renders the file as the machine with the given hostname
+would, using its hosts/<hostname>.toml variables, hostname-dependent
+alternates and hostname template function. Any other machine info (os,
+arch...) is still taken from the current machine.
+`
+
+var RenderCommand cli.Command = cli.Command{
+	Name:        "render",
+	Usage:       "print the rendered contents of a module file",
+	ArgsUsage:   "<module> <file>",
+	Description: renderCommandDescription,
+	Arguments: []cli.Argument{
+		&cli.StringArg{
+			Name:  "moduleName",
+			Value: "",
+		},
+		&cli.StringArg{
+			Name:  "file",
+			Value: "",
+		},
+	},
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "var",
+			Usage: "override a template variable, as key=value (can be repeated)",
+		},
+		&cli.StringFlag{
+			Name:  "host",
+			Value: "",
+			Usage: "render the file as the machine with the given hostname would",
+		},
+	},
+	MutuallyExclusiveFlags: []cli.MutuallyExclusiveFlags{
+		{
+			Required: false,
+			Flags: [][]cli.Flag{
+				{
+					&cli.BoolFlag{
+						Name:    "verbose",
+						Aliases: []string{"v"},
+						Value:   false,
+						Usage:   "show verbose debug info",
+					},
+				},
+				{
+					&cli.BoolFlag{
+						Name:    "quiet",
+						Aliases: []string{"q"},
+						Value:   false,
+						Usage:   "supress most logging output",
+					},
+				},
+			},
+		},
+	},
+	Action: func(ctx context.Context, c *cli.Command) error {
+		appCtx := appcontext.New()
+		cmdCfg := &RenderCommandConfig{
+			ModuleName: filepath.Clean(c.StringArg("moduleName")),
+			File:       c.StringArg("file"),
+			Variables:  c.StringSlice("var"),
+			Host:       c.String("host"),
+			Verbose:    c.Bool("verbose"),
+			Quiet:      c.Bool("quiet"),
+		}
+
+		if cmdCfg.File == "" {
+			return fmt.Errorf("a module and a file must be specified")
+		}
+
+		return ExecuteRender(cmdCfg, appCtx)
+	},
+}
+
+func ExecuteRender(cmdCfg *RenderCommandConfig, appCtx *appcontext.Context) error {
+	if err := logger.InitFileLogging(appCtx.DotfilesDir); err != nil {
+		return fmt.Errorf("could not init file logging: %w", err)
+	}
+	defer logger.CloseDefaultLogFile()
+	logger.SetVerboseMode(cmdCfg.Verbose)
+	logger.SetQuietMode(cmdCfg.Quiet)
+
+	dotfilesDir := appCtx.DotfilesDir
+
+	st, err := state.LoadState(dotfilesDir)
+	if err != nil {
+		return fmt.Errorf("could not load state: %w", err)
+	}
+
+	moduleState := st.Modules[cmdCfg.ModuleName]
+	if moduleState == nil {
+		return fmt.Errorf("the specified module is not managed by peridot")
+	}
+
+	mod, err := module.Load(dotfilesDir, cmdCfg.ModuleName, moduleState)
+	if err != nil {
+		return fmt.Errorf("could not load module %s: %w", cmdCfg.ModuleName, err)
+	}
+
+	system := sysinfo.Current()
+	if cmdCfg.Host != "" {
+		host := *system
+		host.Hostname = cmdCfg.Host
+		system = &host
+	}
+
+	overrides, err := vars.ParseAssignments(cmdCfg.Variables)
+	if err != nil {
+		return err
+	}
+
+	if _, err := mod.ResolveVariables(dotfilesDir, system.Hostname, overrides); err != nil {
+		return err
+	}
+
+	root := mod.Config.Root
+	opts, err := newTemplateOptions(dotfilesDir, mod, root)
+	if err != nil {
+		return err
+	}
+	opts.System = system
+
+	files, err := getFilesToDeploy(dotfilesDir, mod, opts)
+	if err != nil {
+		return fmt.Errorf("could not get files to deploy: %w", err)
+	}
+
+	file, target, err := findModuleFile(dotfilesDir, mod, root, files, cmdCfg.File)
+	if err != nil {
+		return err
+	}
+
+	if !file.Template && !file.Encrypted {
+		return utils.CopyToWriter(file.Source, os.Stdout)
+	}
+
+	// The output is buffered so that nothing is printed if rendering fails
+	var out bytes.Buffer
+	if file.Encrypted {
+		keys, err := crypt.Load(dotfilesDir)
+		if err != nil {
+			return fmt.Errorf("could not load encryption keys: %w", err)
+		}
+
+		if err := decryptToWriter(keys, file.Source, &out); err != nil {
+			return err
+		}
+	} else {
+		opts.Target = target
+		if _, err := templating.RenderFile(file.Source, opts, &out); err != nil {
+			return fmt.Errorf("could not render template: %w", err)
+		}
+	}
+
+	_, err = out.WriteTo(os.Stdout)
+	return err
+}
+
+// findModuleFile returns the file to deploy matching the given path, along
+// with the path it would be deployed at. The path may refer to the file in
+// the module dir (either its source or the path it is deployed as) or to the
+// deployed file itself.
+func findModuleFile(dotfilesDir string, mod *module.Module, root string, files []moduleFile, path string) (*moduleFile, string, error) {
+	moduleDir := paths.ModuleDir(dotfilesDir, mod.Name)
+
+	candidates := []string{}
+	for _, base := range []string{moduleDir, root} {
+		resolved, err := paths.ResolvePath(path, base)
+		if err != nil {
+			return nil, "", err
+		}
+		candidates = append(candidates, resolved)
+	}
+
+	for _, file := range files {
+		target, err := paths.SymlinkPath(file.Path, dotfilesDir, mod.Name, root)
+		if err != nil {
+			return nil, "", fmt.Errorf("could not get potential symlink path: %w", err)
+		}
+
+		for _, candidate := range candidates {
+			if candidate == file.Source || candidate == file.Path || candidate == target {
+				return &file, target, nil
+			}
+		}
+	}
+
+	return nil, "", fmt.Errorf("no file of module %s matches %s", mod.Name, path)
+}
@@ -23,10 +23,16 @@ func Execute() {
 		HideVersion: false,
 		Commands: []*cli.Command{
 			&AddCommand,
+			&DecryptCommand,
 			&DeployCommand,
+			&EncryptCommand,
 			&InitCommand,
+			&PullCommand,
 			&RemoveCommand,
+			&RenderCommand,
 			&StatusCommand,
+			&TemplateCommand,
+			&VarsCommand,
 		},
 	}
 
@@ -2,22 +2,52 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/mermonia/peridot/internal/appcontext"
+	"github.com/mermonia/peridot/internal/hash"
 	"github.com/mermonia/peridot/internal/logger"
+	"github.com/mermonia/peridot/internal/module"
+	"github.com/mermonia/peridot/internal/paths"
 	"github.com/mermonia/peridot/internal/state"
+	"github.com/mermonia/peridot/internal/sysinfo"
 	"github.com/mermonia/peridot/internal/tree"
 	"github.com/urfave/cli/v3"
 )
 
 type StatusCommandConfig struct {
-	ModuleName string
-	Verbose    bool
-	Quiet      bool
+	ModuleName  string
+	Output      string
+	Check       bool
+	Summary     bool
+	Ignored     bool
+	Only        []string
+	Depth       int
+	ModulesOnly bool
+	ASCII       bool
+	Verbose     bool
+	Quiet       bool
 }
 
+const (
+	StatusOutputTree      = "tree"
+	StatusOutputJSON      = "json"
+	StatusOutputPorcelain = "porcelain"
+)
+
+// Exit codes of 'peridot status --check'. Any error exits with 1.
+const (
+	StatusCheckClean    = 0
+	StatusCheckUnsynced = 2
+	StatusCheckDrift    = 3
+)
+
 var statusCommandDescription string = `
 Displays the current state of the peridot dotfiles directory.
 
@@ -34,11 +64,73 @@ A module can be:
 Additionally, files that are part of a deployed module can be:
 	- Up to date
 	- Unsynced
+	- Unsynced because the config changed, if the variables (for
+	templates), the root or the path the file would be deployed at are
+	not the ones it was deployed with. Files deployed with --var are
+	reported as such, as the next deployment would not use them, while
+	a root given with --root is assumed to be given again.
+	- New, if the file was added to the module dir after its last
+	deployment, and would be deployed by the next one.
+With --ignored, the files that deployments skip (ignored files, vars
+files, partials and unselected alternates) are shown as well.
+
+Dirs in the dotfiles dir that contain a module.toml but were not added
+to peridot are shown as unmanaged.
+
+The deployed side of every file is checked as well. Files whose
+deployed symlink or intermediate file changed since they were deployed
+are marked with their own symbol instead:
+	✎ target modified: the deployed file was edited in place (run
+	  'peridot pull' to bring the changes back into the module dir)
+	∅ missing link: the symlink was removed
+	↪ foreign link: the symlink points somewhere else
+	≠ replaced by file: the symlink was replaced by a regular file
+	⚠ intermediate missing: the file the symlink points to was removed
+
+Modules whose conditions are not fulfilled on the current machine are
+annotated with the reason why they are not eligible for deployment.
 
 An unsynced file / module can be updated via the 'peridot deploy'
 command. Doing so will udpate its respective intermediate file
 (run 'peridot deploy --help' for more information).
 
+The --output flag prints the status in a machine-readable format
+instead, meant for scripts and status bars:
+	- json: an object with the schema version and a list of modules,
+	each with its status, deployment time and files. Every file has
+	its source, intermediate and target paths, status, hashes,
+	deployment time and drift.
+	- porcelain: a "# peridot status v<version>" header, then one
+	tab-separated line per module and per file:
+	module <name> <status> <deployed at>
+	file <module> <status> <drift> <path> <source> <intermediate> <target>
+The schema version only changes when the format changes in an
+incompatible way.
+
+The --check flag prints nothing and does not update the state file.
+Instead, it reports the status through the exit code, so that it can
+be used from shell prompts or timers:
+	0 - everything is deployed and up to date
+	1 - an error occurred, or the config or variables of a module
+	    cannot be loaded
+	2 - a file is unsynced or new, or an eligible module is not deployed
+	3 - a deployed file drifted (see above)
+Drift takes precedence over unsynced files. Add --summary to print a
+one-line summary, such as "peridot: 2 unsynced, 0 drifted, 1 not deployed".
+The conditions and dependencies of deployed modules are not checked, as
+they might run commands.
+
+The tree is sorted by name, with dirs before files. It can be narrowed
+down with the following flags, which do not affect the other output
+formats:
+	--only takes a comma-separated list of filters (synced, unsynced,
+	not_deployed, drift, new, ignored, unmanaged) and only shows the
+	files matching any of them, along with their modules.
+	--depth only shows the given number of levels below the root.
+	--modules-only only shows the modules.
+	--ascii draws the tree and the status symbols with ASCII
+	characters, for terminals that cannot display the default ones.
+
 Example output:
 .
 ├── ✓ module1 - deployed and up to date
@@ -63,6 +155,51 @@ var StatusCommand cli.Command = cli.Command{
 			Value: "",
 		},
 	},
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Value:   StatusOutputTree,
+			Usage:   "output format: tree, json or porcelain",
+		},
+		&cli.BoolFlag{
+			Name:    "check",
+			Aliases: []string{"c"},
+			Value:   false,
+			Usage:   "only report the status through the exit code, without modifying the state",
+		},
+		&cli.BoolFlag{
+			Name:    "ignored",
+			Aliases: []string{"i"},
+			Value:   false,
+			Usage:   "also show the files in module dirs that deployments skip",
+		},
+		&cli.StringSliceFlag{
+			Name:  "only",
+			Usage: "only show the modules and files matching any of the given filters: " + strings.Join(state.Filters, ", "),
+		},
+		&cli.IntFlag{
+			Name:  "depth",
+			Value: 0,
+			Usage: "only show this many levels of the tree (0 shows all of them)",
+		},
+		&cli.BoolFlag{
+			Name:    "modules-only",
+			Aliases: []string{"m"},
+			Value:   false,
+			Usage:   "only show the modules, without their files",
+		},
+		&cli.BoolFlag{
+			Name:  "ascii",
+			Value: false,
+			Usage: "draw the tree with ASCII characters only",
+		},
+		&cli.BoolFlag{
+			Name:  "summary",
+			Value: false,
+			Usage: "print a one-line summary of the check",
+		},
+	},
 	MutuallyExclusiveFlags: []cli.MutuallyExclusiveFlags{
 		{
 			Required: false,
@@ -91,8 +228,17 @@ var StatusCommand cli.Command = cli.Command{
 		appCtx := appcontext.New()
 
 		cmdCfg := &StatusCommandConfig{
-			Verbose: c.Bool("verbose"),
-			Quiet:   c.Bool("quiet"),
+			ModuleName:  c.StringArg("moduleName"),
+			Output:      c.String("output"),
+			Check:       c.Bool("check"),
+			Summary:     c.Bool("summary"),
+			Ignored:     c.Bool("ignored"),
+			Only:        c.StringSlice("only"),
+			Depth:       int(c.Int("depth")),
+			ModulesOnly: c.Bool("modules-only"),
+			ASCII:       c.Bool("ascii"),
+			Verbose:     c.Bool("verbose"),
+			Quiet:       c.Bool("quiet"),
 		}
 		return ExecuteStatus(appCtx, cmdCfg)
 	},
@@ -106,6 +252,38 @@ func ExecuteStatus(appCtx *appcontext.Context, cmdCfg *StatusCommandConfig) erro
 	logger.SetVerboseMode(cmdCfg.Verbose)
 	logger.SetQuietMode(cmdCfg.Quiet)
 
+	if cmdCfg.Summary && !cmdCfg.Check {
+		return fmt.Errorf("the summary option can only be used along with check")
+	}
+
+	for _, filter := range cmdCfg.Only {
+		if !slices.Contains(state.Filters, filter) {
+			return fmt.Errorf("invalid filter %q, expected any of %s", filter, strings.Join(state.Filters, ", "))
+		}
+	}
+
+	if cmdCfg.Depth < 0 {
+		return fmt.Errorf("the depth cannot be negative")
+	}
+
+	switch cmdCfg.Output {
+	case StatusOutputTree:
+	case StatusOutputJSON, StatusOutputPorcelain:
+		// Nothing but the report may be written to stdout
+		logger.SetVerboseMode(false)
+		logger.SetQuietMode(true)
+	default:
+		return fmt.Errorf("invalid output format %q, expected one of %s, %s or %s", cmdCfg.Output,
+			StatusOutputTree, StatusOutputJSON, StatusOutputPorcelain)
+	}
+
+	// Nothing but the summary may be written, as it might run on every
+	// prompt redraw
+	if cmdCfg.Check {
+		logger.SetVerboseMode(false)
+		logger.SetQuietMode(true)
+	}
+
 	st, err := state.LoadState(appCtx.DotfilesDir)
 	if err != nil {
 		return fmt.Errorf("could not load state: %w", err)
@@ -115,12 +293,41 @@ func ExecuteStatus(appCtx *appcontext.Context, cmdCfg *StatusCommandConfig) erro
 		return fmt.Errorf("could not refresh state: %w", err)
 	}
 
+	markConfigChanges(st, appCtx.DotfilesDir)
+
+	newFiles, ignoredFiles := getUntrackedFiles(st, appCtx.DotfilesDir, cmdCfg.Ignored)
+	treeOpts := &state.TreeOptions{
+		Notes:        getIneligibilityNotes(st, appCtx.DotfilesDir, cmdCfg.Check),
+		NewFiles:     newFiles,
+		IgnoredFiles: ignoredFiles,
+	}
 	if cmdCfg.ModuleName == "" {
-		if err := printStateTree(st, appCtx.DotfilesDir); err != nil {
+		treeOpts.Unmanaged = getUnmanagedModules(st, appCtx.DotfilesDir)
+	}
+	if cmdCfg.Output == StatusOutputTree {
+		treeOpts.Only = cmdCfg.Only
+		treeOpts.ModulesOnly = cmdCfg.ModulesOnly
+		if cmdCfg.ASCII {
+			treeOpts.Symbols = &state.ASCIIStatusSymbols
+		}
+	}
+
+	// The state is not saved, so that the check can run as often as needed
+	// (e.g. from a prompt) without touching the dotfiles dir
+	if cmdCfg.Check {
+		return checkStatus(st, cmdCfg, treeOpts)
+	}
+
+	if cmdCfg.Output != StatusOutputTree {
+		if err := printStatusReport(st, appCtx.DotfilesDir, cmdCfg, treeOpts); err != nil {
+			return err
+		}
+	} else if cmdCfg.ModuleName == "" {
+		if err := printStateTree(st, appCtx.DotfilesDir, cmdCfg, treeOpts); err != nil {
 			return err
 		}
 	} else {
-		if err := printModuleTree(st, appCtx.DotfilesDir, cmdCfg.ModuleName); err != nil {
+		if err := printModuleTree(st, appCtx.DotfilesDir, cmdCfg, treeOpts); err != nil {
 			return err
 		}
 	}
@@ -133,27 +340,321 @@ func ExecuteStatus(appCtx *appcontext.Context, cmdCfg *StatusCommandConfig) erro
 	return nil
 }
 
-func printStateTree(st *state.State, dotfilesDir string) error {
-	tr, err := state.GetStateFileTree(st, dotfilesDir)
+// Prefixes of the notes of modules that cannot be loaded at all.
+const (
+	invalidConfigNote    = "invalid config: "
+	invalidVariablesNote = "invalid variables: "
+)
+
+// getIneligibilityNotes explains, for every module that could not be deployed
+// on the current machine, which of its conditions or dependencies is not
+// fulfilled. Unless validateOnly is set, in which case the conditions and
+// dependencies of deployed modules are skipped, as they might run commands.
+func getIneligibilityNotes(st *state.State, dotfilesDir string, validateOnly bool) map[string]string {
+	notes := map[string]string{}
+
+	for name, moduleState := range st.Modules {
+		mod, err := module.Load(dotfilesDir, name, moduleState)
+		if err != nil {
+			notes[name] = invalidConfigNote + err.Error()
+			logger.Warn("Could not load module config", "module", name, "error", err.Error())
+			continue
+		}
+
+		if _, err := mod.ResolveVariables(dotfilesDir, sysinfo.Current().Hostname, nil); err != nil {
+			notes[name] = invalidVariablesNote + err.Error()
+			continue
+		}
+
+		if validateOnly && moduleState.Status != state.NotDeployed {
+			continue
+		}
+
+		c := mod.Config
+		if err := c.Conditions.Check(sysinfo.Current(), mod.Variables); err != nil {
+			notes[name] = "ineligible: " + err.Error()
+		} else if unmet := c.UnmetDependencies(); len(unmet) > 0 {
+			notes[name] = "unmet dependencies: " + strings.Join(unmet, "; ")
+		}
+	}
+
+	return notes
+}
+
+// markConfigChanges sets the ConfigChanges of the deployed files whose
+// variables, root or target differ from what the next deployment would use.
+func markConfigChanges(st *state.State, dotfilesDir string) {
+	for name, moduleState := range st.Modules {
+		if moduleState.Status == state.NotDeployed {
+			continue
+		}
+
+		// Modules that cannot be loaded are already reported by their notes
+		mod, err := module.Load(dotfilesDir, name, moduleState)
+		if err != nil {
+			continue
+		}
+
+		if _, err := mod.ResolveVariables(dotfilesDir, sysinfo.Current().Hostname, nil); err != nil {
+			continue
+		}
+
+		variablesHash, err := hash.HashValue(mod.Variables)
+		if err != nil {
+			continue
+		}
+
+		templateOpts, err := newTemplateOptions(dotfilesDir, mod, mod.Config.Root)
+		if err != nil {
+			continue
+		}
+
+		files, err := getFilesToDeploy(dotfilesDir, mod, templateOpts)
+		if err != nil {
+			continue
+		}
+
+		deployPaths := map[string]string{}
+		for _, file := range files {
+			deployPaths[file.Source] = file.Path
+		}
+
+		for source, entry := range moduleState.Files {
+			// Files deployed before the configuration was recorded along
+			// with them cannot be compared
+			if entry.Root == "" {
+				continue
+			}
+
+			changes := []string{}
+			if entry.VariablesHash != "" && entry.VariablesHash != variablesHash {
+				changes = append(changes, "variables")
+			}
+
+			root := mod.Config.Root
+			if entry.RootOverridden {
+				root = entry.Root
+			} else if root != entry.Root {
+				changes = append(changes, "root")
+			}
+
+			if path, ok := deployPaths[source]; ok {
+				if entry.Dotreplace {
+					path = paths.GetDotreplacedPath(path)
+				}
+
+				target, err := paths.SymlinkPath(path, dotfilesDir, name, root)
+				if err == nil && target != entry.SymlinkPath {
+					changes = append(changes, "target")
+				}
+			}
+
+			if len(changes) > 0 {
+				entry.ConfigChanges = changes
+			}
+		}
+	}
+}
+
+// getUntrackedFiles finds the files in every module dir that are not in the
+// state, split into the ones the next deployment would link and, if ignored
+// is set, the ones deployments skip.
+func getUntrackedFiles(st *state.State, dotfilesDir string, ignored bool) (map[string][]string, map[string][]string) {
+	newFiles := map[string][]string{}
+	ignoredFiles := map[string][]string{}
+
+	for name, moduleState := range st.Modules {
+		// Modules that cannot be loaded are already reported by their notes
+		mod, err := module.Load(dotfilesDir, name, moduleState)
+		if err != nil {
+			continue
+		}
+
+		if _, err := mod.ResolveVariables(dotfilesDir, sysinfo.Current().Hostname, nil); err != nil {
+			continue
+		}
+
+		templateOpts, err := newTemplateOptions(dotfilesDir, mod, mod.Config.Root)
+		if err != nil {
+			continue
+		}
+
+		files, err := getFilesToDeploy(dotfilesDir, mod, templateOpts)
+		if err != nil {
+			logger.Warn("Could not list the files to deploy", "module", name, "error", err.Error())
+			continue
+		}
+
+		deployable := map[string]bool{}
+		for _, file := range files {
+			deployable[file.Source] = true
+			if moduleState.Files[file.Source] == nil {
+				newFiles[name] = append(newFiles[name], file.Source)
+			}
+		}
+
+		if !ignored {
+			continue
+		}
+
+		err = filepath.WalkDir(paths.ModuleDir(dotfilesDir, name), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !d.IsDir() && !deployable[path] && moduleState.Files[path] == nil {
+				ignoredFiles[name] = append(ignoredFiles[name], path)
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Warn("Could not walk module dir", "module", name, "error", err.Error())
+		}
+	}
+
+	return newFiles, ignoredFiles
+}
+
+// getUnmanagedModules lists the dirs in the dotfiles dir that contain a module
+// config, but were never added to the state.
+func getUnmanagedModules(st *state.State, dotfilesDir string) []string {
+	entries, err := os.ReadDir(dotfilesDir)
+	if err != nil {
+		logger.Warn("Could not read dotfiles dir", "error", err.Error())
+		return nil
+	}
+
+	unmanaged := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() || st.Modules[entry.Name()] != nil {
+			continue
+		}
+
+		configPath := filepath.Join(paths.ModuleDir(dotfilesDir, entry.Name()), paths.ModuleConfigFileName)
+		if _, err := os.Stat(configPath); err == nil {
+			unmanaged = append(unmanaged, entry.Name())
+		}
+	}
+
+	return unmanaged
+}
+
+func printStateTree(st *state.State, dotfilesDir string, cmdCfg *StatusCommandConfig, opts *state.TreeOptions) error {
+	tr, err := state.GetStateFileTree(st, dotfilesDir, opts)
 	if err != nil {
 		return fmt.Errorf("could not get state file tree: %w", err)
 	}
 
-	tree.PrintTree(tr, tree.DefaultTreeBranchSymbols, os.Stdout)
+	printTree(tr, cmdCfg)
 	return nil
 }
 
-func printModuleTree(st *state.State, dotfilesDir, moduleName string) error {
-	moduleState := st.Modules[moduleName]
+func printModuleTree(st *state.State, dotfilesDir string, cmdCfg *StatusCommandConfig, opts *state.TreeOptions) error {
+	moduleState := st.Modules[cmdCfg.ModuleName]
 	if moduleState == nil {
 		return fmt.Errorf("cannot print a non-existing module")
 	}
 
-	tr, err := state.GetModuleFileTree(moduleName, moduleState, dotfilesDir)
+	tr, err := state.GetModuleFileTree(cmdCfg.ModuleName, moduleState, dotfilesDir, opts)
 	if err != nil {
 		return fmt.Errorf("could not get module file tree: %w", err)
 	}
 
-	tree.PrintTree(tr, tree.DefaultTreeBranchSymbols, os.Stdout)
+	printTree(tr, cmdCfg)
 	return nil
 }
+
+func printTree(tr *tree.Node, cmdCfg *StatusCommandConfig) {
+	if cmdCfg.Depth > 0 {
+		tr.Truncate(cmdCfg.Depth)
+	}
+
+	syms := tree.DefaultTreeBranchSymbols
+	if cmdCfg.ASCII {
+		syms = tree.ASCIITreeBranchSymbols
+	}
+
+	tree.PrintTree(tr, syms, os.Stdout)
+}
+
+// checkStatus exits with StatusCheckDrift if any deployed file drifted, or
+// with StatusCheckUnsynced if any file is unsynced or new or any module that
+// could be deployed was not. Otherwise, it returns nil, so that the exit code
+// is 0. Modules whose config or variables cannot be loaded are errors, as
+// their status is unknown.
+func checkStatus(st *state.State, cmdCfg *StatusCommandConfig, opts *state.TreeOptions) error {
+	if cmdCfg.ModuleName != "" && st.Modules[cmdCfg.ModuleName] == nil {
+		return fmt.Errorf("cannot check a non-existing module")
+	}
+
+	unsynced, drifted, notDeployed := 0, 0, 0
+	for name, moduleState := range st.Modules {
+		if cmdCfg.ModuleName != "" && name != cmdCfg.ModuleName {
+			continue
+		}
+
+		if note := opts.Notes[name]; strings.HasPrefix(note, invalidConfigNote) ||
+			strings.HasPrefix(note, invalidVariablesNote) {
+			return fmt.Errorf("could not check module %s: %s", name, note)
+		}
+
+		if moduleState.Status == state.NotDeployed {
+			if opts.Notes[name] == "" {
+				notDeployed++
+			}
+			continue
+		}
+
+		unsynced += len(opts.NewFiles[name])
+		for _, entry := range moduleState.Files {
+			if entry.EffectiveStatus() == state.Unsynced {
+				unsynced++
+			}
+			if entry.Drift != state.NoDrift {
+				drifted++
+			}
+		}
+	}
+
+	if cmdCfg.Summary {
+		if unsynced+drifted+notDeployed == 0 {
+			fmt.Println("peridot: clean")
+		} else {
+			fmt.Printf("peridot: %d unsynced, %d drifted, %d not deployed\n", unsynced, drifted, notDeployed)
+		}
+	}
+
+	switch {
+	case drifted > 0:
+		return cli.Exit("", StatusCheckDrift)
+	case unsynced > 0 || notDeployed > 0:
+		return cli.Exit("", StatusCheckUnsynced)
+	default:
+		return nil
+	}
+}
+
+// printStatusReport prints the machine-readable report of the state, or of a
+// single module, in the requested format.
+func printStatusReport(st *state.State, dotfilesDir string, cmdCfg *StatusCommandConfig, opts *state.TreeOptions) error {
+	if cmdCfg.ModuleName != "" {
+		moduleState := st.Modules[cmdCfg.ModuleName]
+		if moduleState == nil {
+			return fmt.Errorf("cannot print a non-existing module")
+		}
+		st = &state.State{Modules: map[string]*state.ModuleState{cmdCfg.ModuleName: moduleState}}
+	}
+
+	report, err := state.NewReport(st, dotfilesDir, opts)
+	if err != nil {
+		return fmt.Errorf("could not build status report: %w", err)
+	}
+
+	if cmdCfg.Output == StatusOutputPorcelain {
+		return report.WritePorcelain(os.Stdout)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
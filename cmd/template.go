@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/urfave/cli/v3"
+)
+
+var templateCommandDescription string = `
+Template files (every text file by default, see the [template] section
+of module.toml) are rendered as Go templates (see
+https://pkg.go.dev/text/template) before being deployed. The module's
+variables (run 'peridot vars --help') are available as the template data,
+e.g. {{ .font }}.
+
+The reserved .peridot variable is always available, and holds:
+	.peridot.hostname     hostname of the current machine
+	.peridot.os           operating system (linux, darwin, windows...)
+	.peridot.arch         architecture (amd64, arm64...)
+	.peridot.username     name of the current user
+	.peridot.homeDir      home directory of the current user
+	.peridot.module       name of the module being deployed
+	.peridot.moduleDir    absolute path of the module dir
+	.peridot.root         root the module is deployed to
+	.peridot.target       path the rendered file is deployed at
+	.peridot.dotfilesDir  absolute path of the dotfiles dir
+
+On top of Go's built-in functions (and, or, not, eq, printf, len,
+index...), the following functions are available:
+
+System:
+	env "NAME"          value of an environment variable, "" if unset
+	hostname            hostname of the current machine
+	os                  operating system (linux, darwin, windows...)
+	arch                architecture (amd64, arm64...)
+	homeDir             home directory of the current user
+	xdg "config"        XDG base dir: config, data, state, cache, bin
+	                    or runtime, honoring the XDG_*_HOME variables
+	lookPath "nvim"     absolute path of a binary in PATH, "" if missing
+	exists "~/.cargo"   whether a file or directory exists
+
+Strings and values:
+	default "x" .val    .val, or "x" if .val is empty. In strict templates,
+	                    undefined variables must be read with index:
+	                    {{ index . "font" | default "monospace" }}
+	upper .s            converts to upper case
+	lower .s            converts to lower case
+	replace "a" "b" .s  replaces every "a" in .s with "b"
+	trim .s             removes leading and trailing whitespace
+	joinPath "a" "b"    joins path elements: a/b
+	toJson .val         encodes a value (e.g. a list or table) as JSON
+	toToml .val         encodes a table as TOML
+
+Files:
+	include "path"      raw content of another file, without rendering it
+
+Secrets:
+	secret "name"       value of a secret from the default provider
+	secret "prov" "name"
+	                    value of a secret from the given provider
+
+Relative paths passed to exists and include are resolved against the
+module dir, and a leading "~" is expanded to the home directory.
+
+Secrets are never stored in the dotfiles dir. Instead, they are looked
+up (once per run) through the providers configured in peridot.toml:
+	[secrets]
+	default = "pass"
+
+	[secrets.providers.pass]
+	type = "command"             # output of the command, {name} is
+	command = "pass show {name}" # replaced by the name of the secret
+
+	[secrets.providers.env]
+	type = "env"                 # e.g. secret "env" "gh/token" reads
+	prefix = "SECRET_"           # the SECRET_GH_TOKEN variable
+
+	[secrets.providers.local]
+	type = "file"                # TOML file, relative to DOTFILES_DIR,
+	path = "secrets.toml"        # that must not be committed
+
+Files that use secrets are rendered with mode 0600. 'peridot template
+check' does not look up secrets, but still validates their providers.
+
+By default, templates are strict: referencing an undefined variable
+makes rendering fail instead of producing "<no value>". Optional
+variables can be read with {{ index . "name" }}, which yields an empty
+value when undefined, e.g. {{ index . "font" | default "monospace" }}.
+Set strict = false in the module's [template] section to opt out.
+
+File and dir names are rendered as well, unless the module's template
+mode is "none", so that ".config/{{ .peridot.hostname }}/monitors.conf"
+is deployed as ".config/laptop/monitors.conf". Every templated name must
+render to a valid file name, and two files rendering to the same path
+make the deployment fail before any file is touched.
+
+Partials are reusable snippets shared across templates. Every file in
+DOTFILES_DIR/_templates and in the module's own _partials dir is parsed
+along with each template, and can be invoked by its name without the
+extension. For a partial stored as _templates/colors.tmpl:
+	{{ template "colors" . }}
+
+Module partials take precedence over shared ones with the same name, and
+{{ define }} blocks inside partials are available as well. Files that use
+a partial are marked as unsynced when the partial changes.
+
+Delimiters other than {{ and }} can be set for a whole module with
+left_delim and right_delim in its [template] section, or for a single
+file with a comment on its first line (or right after a shebang),
+which is removed on render:
+	# peridot:delims [[ ]]
+
+Example:
+	font = "{{ index . "font" | default "monospace" }}"
+	{{ if lookPath "starship" }}eval "$(starship init zsh)"{{ end }}
+	source {{ joinPath (xdg "config") "zsh" "aliases.zsh" }}
+`
+
+var TemplateCommand cli.Command = cli.Command{
+	Name:        "template",
+	Aliases:     []string{"templates", "t"},
+	Usage:       "template reference and tools (run 'peridot help templates')",
+	Description: templateCommandDescription,
+	Commands: []*cli.Command{
+		&TemplateCheckCommand,
+	},
+	Action: func(ctx context.Context, c *cli.Command) error {
+		return cli.ShowCommandHelp(ctx, c.Root(), c.Name)
+	},
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+
+	"github.com/mermonia/peridot/internal/appcontext"
+	"github.com/mermonia/peridot/internal/logger"
+	"github.com/mermonia/peridot/internal/module"
+	"github.com/mermonia/peridot/internal/paths"
+	"github.com/mermonia/peridot/internal/state"
+	"github.com/mermonia/peridot/internal/sysinfo"
+	"github.com/mermonia/peridot/internal/templating"
+	"github.com/urfave/cli/v3"
+)
+
+type TemplateCheckCommandConfig struct {
+	ModuleName string
+	Verbose    bool
+	Quiet      bool
+}
+
+var templateCheckCommandDescription string = `
+Parses and renders every template file of a module (or of every managed
+module, if none is specified) without deploying anything, and reports:
+	- Parse and render errors, with their file:line:column
+	- References to undefined variables
+	- Variables defined in a module's module.toml that no template uses
+
+Undefined variables are errors in strict mode (the default) and warnings
+otherwise. Unused variables are always warnings.
+
+The command exits with a non-zero status if any error was found, so it
+can be used as a pre-commit hook:
+	peridot template check --quiet
+`
+
+var TemplateCheckCommand cli.Command = cli.Command{
+	Name:        "check",
+	Aliases:     []string{"c"},
+	Usage:       "check templates for errors and undefined or unused variables",
+	ArgsUsage:   "[module]",
+	Description: templateCheckCommandDescription,
+	Arguments: []cli.Argument{
+		&cli.StringArg{
+			Name:  "moduleName",
+			Value: "",
+		},
+	},
+	MutuallyExclusiveFlags: []cli.MutuallyExclusiveFlags{
+		{
+			Required: false,
+			Flags: [][]cli.Flag{
+				{
+					&cli.BoolFlag{
+						Name:    "verbose",
+						Aliases: []string{"v"},
+						Value:   false,
+						Usage:   "show verbose debug info",
+					},
+				},
+				{
+					&cli.BoolFlag{
+						Name:    "quiet",
+						Aliases: []string{"q"},
+						Value:   false,
+						Usage:   "supress most logging output",
+					},
+				},
+			},
+		},
+	},
+	Action: func(ctx context.Context, c *cli.Command) error {
+		appCtx := appcontext.New()
+		cmdCfg := &TemplateCheckCommandConfig{
+			ModuleName: c.StringArg("moduleName"),
+			Verbose:    c.Bool("verbose"),
+			Quiet:      c.Bool("quiet"),
+		}
+
+		return ExecuteTemplateCheck(cmdCfg, appCtx)
+	},
+}
+
+func ExecuteTemplateCheck(cmdCfg *TemplateCheckCommandConfig, appCtx *appcontext.Context) error {
+	if err := logger.InitFileLogging(appCtx.DotfilesDir); err != nil {
+		return fmt.Errorf("could not init file logging: %w", err)
+	}
+	defer logger.CloseDefaultLogFile()
+	logger.SetVerboseMode(cmdCfg.Verbose)
+	logger.SetQuietMode(cmdCfg.Quiet)
+
+	st, err := state.LoadState(appCtx.DotfilesDir)
+	if err != nil {
+		return fmt.Errorf("could not load state: %w", err)
+	}
+
+	moduleNames := slices.Sorted(maps.Keys(st.Modules))
+	if cmdCfg.ModuleName != "" {
+		if st.Modules[cmdCfg.ModuleName] == nil {
+			return fmt.Errorf("the specified module is not managed by peridot")
+		}
+		moduleNames = []string{cmdCfg.ModuleName}
+	}
+
+	checked, errorCount, warningCount := 0, 0, 0
+	for _, name := range moduleNames {
+		mod, err := module.Load(appCtx.DotfilesDir, name, st.Modules[name])
+		if err != nil {
+			return fmt.Errorf("could not load module %s: %w", name, err)
+		}
+
+		issues, count, err := checkModuleTemplates(appCtx.DotfilesDir, mod)
+		if err != nil {
+			return fmt.Errorf("could not check templates of module %s: %w", name, err)
+		}
+		checked += count
+
+		for _, issue := range issues {
+			severity := "error"
+			if issue.Warning {
+				severity = "warning"
+				warningCount++
+			} else {
+				errorCount++
+			}
+
+			fmt.Printf("%s: %s: %s\n", filepath.Join(name, issue.Location), severity, issue.Message)
+		}
+	}
+
+	logger.Info(fmt.Sprintf("Checked %d template(s): %d error(s), %d warning(s)",
+		checked, errorCount, warningCount))
+
+	if errorCount > 0 {
+		return cli.Exit("", 1)
+	}
+
+	logger.Info("Successfully executed command!", "command", "template check")
+	return nil
+}
+
+// checkModuleTemplates checks every template a deployment of the module would
+// render, returning the issues found and the number of templates checked.
+func checkModuleTemplates(dotfilesDir string, mod *module.Module) ([]templating.Issue, int, error) {
+	if _, err := mod.ResolveVariables(dotfilesDir, sysinfo.Current().Hostname, nil); err != nil {
+		return nil, 0, err
+	}
+
+	templateOpts, err := newTemplateOptions(dotfilesDir, mod, mod.Config.Root)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Files with templated names that fail to render make it impossible to
+	// tell which files would be deployed
+	files, err := getFilesToDeploy(dotfilesDir, mod, templateOpts)
+	if err != nil {
+		return []templating.Issue{templating.ErrorIssue(mod.Name, err)}, 0, nil
+	}
+
+	moduleDir := paths.ModuleDir(dotfilesDir, mod.Name)
+	issues := []templating.Issue{}
+	used := map[string]bool{}
+	usesAll := false
+	checked := 0
+
+	for _, file := range files {
+		if mod.Config.Template.TemplatedNames() {
+			rel, err := filepath.Rel(moduleDir, file.Source)
+			if err != nil {
+				return nil, 0, fmt.Errorf("could not relativize path: %w", err)
+			}
+
+			result := templating.CheckPath(rel, templateOpts)
+			issues = append(issues, result.Issues...)
+			maps.Copy(used, result.UsedVariables)
+		}
+
+		if !file.Template {
+			continue
+		}
+
+		opts := *templateOpts
+		if opts.Target, err = paths.SymlinkPath(file.Path, dotfilesDir, mod.Name, opts.Root); err != nil {
+			return nil, 0, err
+		}
+
+		result := templating.Check(file.Source, &opts)
+		issues = append(issues, result.Issues...)
+		maps.Copy(used, result.UsedVariables)
+		usesAll = usesAll || result.UsesAll
+		checked++
+	}
+
+	if !usesAll {
+		for _, name := range slices.Sorted(maps.Keys(mod.Config.TemplateVariables)) {
+			if !used[name] {
+				issues = append(issues, templating.Issue{
+					Location: paths.ModuleConfigFileName,
+					Message:  fmt.Sprintf("variable %q is defined but never used", name),
+					Warning:  true,
+				})
+			}
+		}
+	}
+
+	return issues, checked, nil
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/mermonia/peridot/internal/appcontext"
+	"github.com/mermonia/peridot/internal/logger"
+	"github.com/mermonia/peridot/internal/module"
+	"github.com/mermonia/peridot/internal/state"
+	"github.com/mermonia/peridot/internal/sysinfo"
+	"github.com/mermonia/peridot/internal/vars"
+	"github.com/urfave/cli/v3"
+)
+
+type VarsCommandConfig struct {
+	ModuleName string
+	Variables  []string
+	Verbose    bool
+	Quiet      bool
+}
+
+var varsCommandDescription string = `
+Prints the effective template variables of a module, along with the
+layer each of them comes from. If no module is specified, only the
+dotfiles-level layers are taken into account.
+
+Variables are merged from the following layers, each one overriding
+the previous ones:
+	1. [variables] in DOTFILES_DIR/peridot.toml
+	2. [variables] in DOTFILES_DIR/hosts/<hostname>.toml
+	3. The files in the module's vars_files, in order
+	4. [variables] in the module's module.toml
+	5. --var key=value flags passed to the command
+
+Tables are merged key by key, so a host file can override a single
+color of a palette defined in peridot.toml. Any other value (strings,
+numbers, lists...) is replaced as a whole.
+
+Example output:
+	colors.accent  "#ff79c6"  hosts/laptop.toml
+	colors.bg      "#282a36"  peridot.toml
+	email          "me@x.io"  peridot.toml
+	font           "Iosevka"  module.toml
+`
+
+var VarsCommand cli.Command = cli.Command{
+	Name:        "vars",
+	Usage:       "show the effective template variables of a module",
+	ArgsUsage:   "[module]",
+	Description: varsCommandDescription,
+	Arguments: []cli.Argument{
+		&cli.StringArg{
+			Name:  "moduleName",
+			Value: "",
+		},
+	},
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "var",
+			Usage: "override a template variable, as key=value (can be repeated)",
+		},
+	},
+	MutuallyExclusiveFlags: []cli.MutuallyExclusiveFlags{
+		{
+			Required: false,
+			Flags: [][]cli.Flag{
+				{
+					&cli.BoolFlag{
+						Name:    "verbose",
+						Aliases: []string{"v"},
+						Value:   false,
+						Usage:   "show verbose debug info",
+					},
+				},
+				{
+					&cli.BoolFlag{
+						Name:    "quiet",
+						Aliases: []string{"q"},
+						Value:   false,
+						Usage:   "supress most logging output",
+					},
+				},
+			},
+		},
+	},
+	Action: func(ctx context.Context, c *cli.Command) error {
+		appCtx := appcontext.New()
+		cmdCfg := &VarsCommandConfig{
+			ModuleName: c.StringArg("moduleName"),
+			Variables:  c.StringSlice("var"),
+			Verbose:    c.Bool("verbose"),
+			Quiet:      c.Bool("quiet"),
+		}
+
+		return ExecuteVars(cmdCfg, appCtx)
+	},
+}
+
+func ExecuteVars(cmdCfg *VarsCommandConfig, appCtx *appcontext.Context) error {
+	if err := logger.InitFileLogging(appCtx.DotfilesDir); err != nil {
+		return fmt.Errorf("could not init file logging: %w", err)
+	}
+	defer logger.CloseDefaultLogFile()
+	logger.SetVerboseMode(cmdCfg.Verbose)
+	logger.SetQuietMode(cmdCfg.Quiet)
+
+	overrides, err := vars.ParseAssignments(cmdCfg.Variables)
+	if err != nil {
+		return err
+	}
+
+	hostname := sysinfo.Current().Hostname
+
+	var set *vars.Set
+	if cmdCfg.ModuleName == "" {
+		set, err = vars.Resolve(appCtx.DotfilesDir, hostname, nil, overrides)
+		if err != nil {
+			return fmt.Errorf("could not resolve variables: %w", err)
+		}
+	} else {
+		st, err := state.LoadState(appCtx.DotfilesDir)
+		if err != nil {
+			return fmt.Errorf("could not load state: %w", err)
+		}
+
+		moduleState := st.Modules[cmdCfg.ModuleName]
+		if moduleState == nil {
+			return fmt.Errorf("the specified module is not managed by peridot")
+		}
+
+		mod, err := module.Load(appCtx.DotfilesDir, cmdCfg.ModuleName, moduleState)
+		if err != nil {
+			return fmt.Errorf("could not load module %s: %w", cmdCfg.ModuleName, err)
+		}
+
+		if set, err = mod.ResolveVariables(appCtx.DotfilesDir, hostname, overrides); err != nil {
+			return err
+		}
+	}
+
+	return printVariables(set)
+}
+
+func printVariables(set *vars.Set) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	for _, leaf := range set.Leaves() {
+		value, _ := set.Lookup(leaf)
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("could not format variable %s: %w", leaf, err)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\n", leaf, encoded, set.Sources[leaf])
+	}
+
+	return w.Flush()
+}
@@ -3,13 +3,16 @@ module github.com/mermonia/peridot
 go 1.24.6
 
 require (
+	filippo.io/age v1.2.1
 	github.com/BurntSushi/toml v1.5.0
 	github.com/fatih/color v1.18.0
 	github.com/urfave/cli/v3 v3.4.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
+	golang.org/x/crypto v0.24.0 // indirect
 	golang.org/x/sys v0.25.0 // indirect
 )
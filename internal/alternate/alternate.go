@@ -0,0 +1,147 @@
+package alternate
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mermonia/peridot/internal/sysinfo"
+)
+
+// Separator splits the base name of an alternate file from its conditions,
+// as in "monitors.conf##hostname.laptop".
+const Separator = "##"
+
+// Conditions supported in an alternate suffix, along with their weight.
+// When several alternates match, the one with the highest total weight wins,
+// so that more specific conditions take precedence.
+var attributeWeights = map[string]int{
+	"default":  0,
+	"arch":     1,
+	"os":       2,
+	"distro":   4,
+	"class":    8,
+	"hostname": 16,
+	"user":     32,
+}
+
+var attributeAliases = map[string]string{
+	"a": "arch",
+	"o": "os",
+	"d": "distro",
+	"c": "class",
+	"h": "hostname",
+	"u": "user",
+}
+
+// Split separates the base name of an alternate from its suffix. If the name
+// is not an alternate, the name itself is returned with an empty suffix.
+func Split(name string) (base, suffix string, isAlternate bool) {
+	return strings.Cut(name, Separator)
+}
+
+// Score checks the conditions in an alternate suffix (e.g. "os.darwin,class.work")
+// against the given machine. It reports whether all of them are fulfilled and,
+// if so, how specific the match is.
+func Score(suffix string, info *sysinfo.Info) (int, bool, error) {
+	score := 0
+	matches := true
+
+	for _, condition := range strings.Split(suffix, ",") {
+		attribute, value, _ := strings.Cut(condition, ".")
+		attribute = strings.ToLower(attribute)
+		if alias, ok := attributeAliases[attribute]; ok {
+			attribute = alias
+		}
+
+		weight, ok := attributeWeights[attribute]
+		if !ok {
+			return 0, false, fmt.Errorf("unknown alternate condition %q in %q", attribute, suffix)
+		}
+
+		if attribute != "default" && value == "" {
+			return 0, false, fmt.Errorf("alternate condition %q in %q has no value", attribute, suffix)
+		}
+
+		score += weight
+		if !conditionMatches(attribute, value, info) {
+			matches = false
+		}
+	}
+
+	return score, matches, nil
+}
+
+func conditionMatches(attribute, value string, info *sysinfo.Info) bool {
+	switch attribute {
+	case "default":
+		return true
+	case "arch":
+		return strings.EqualFold(value, info.Arch)
+	case "os":
+		return strings.EqualFold(value, info.OS)
+	case "distro":
+		return strings.EqualFold(value, info.Distro)
+	case "class":
+		return slices.Contains(info.Classes, value)
+	case "hostname":
+		return value == info.Hostname
+	case "user":
+		return value == info.Username
+	}
+
+	return false
+}
+
+// Select groups the given paths by the file they are alternates of, and picks
+// the best matching candidate of each group. The result maps the path each
+// file should be deployed as (without any alternate suffix) to the selected
+// source path. Paths that are not alternates are always selected, but lose
+// against any matching alternate of the same file.
+func Select(paths []string, info *sysinfo.Info) (map[string]string, error) {
+	selected := map[string]string{}
+	bestScores := map[string]int{}
+	// Candidates with the same score as the selected one. It is only an
+	// error if no other candidate of the group scores higher.
+	tied := map[string]string{}
+
+	for _, path := range paths {
+		dir, name := filepath.Split(path)
+		base, suffix, isAlternate := Split(name)
+		target := filepath.Join(dir, base)
+
+		score := -1
+		if isAlternate {
+			s, matches, err := Score(suffix, info)
+			if err != nil {
+				return nil, fmt.Errorf("invalid alternate %s: %w", path, err)
+			}
+			if !matches {
+				continue
+			}
+			score = s
+		}
+
+		best, exists := bestScores[target]
+		if exists && best > score {
+			continue
+		}
+		if exists && best == score {
+			tied[target] = path
+			continue
+		}
+
+		selected[target] = path
+		bestScores[target] = score
+		delete(tied, target)
+	}
+
+	if len(tied) > 0 {
+		target := slices.Min(slices.Collect(maps.Keys(tied)))
+		return nil, fmt.Errorf("alternates %s and %s are equally specific", selected[target], tied[target])
+	}
+
+	return selected, nil
+}
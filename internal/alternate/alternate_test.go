@@ -0,0 +1,77 @@
+package alternate
+
+import (
+	"testing"
+
+	"github.com/mermonia/peridot/internal/sysinfo"
+)
+
+func TestSelect(t *testing.T) {
+	info := &sysinfo.Info{OS: "linux", Arch: "amd64", Hostname: "laptop", Username: "me", Classes: []string{"work"}}
+
+	paths := []string{
+		"/m/monitors.conf",
+		"/m/monitors.conf##os.darwin",
+		"/m/monitors.conf##os.linux",
+		"/m/monitors.conf##hostname.laptop",
+		"/m/gitconfig##default",
+		"/m/gitconfig##os.linux,class.work",
+		"/m/gitconfig##class.home",
+		"/m/only##hostname.desktop",
+	}
+
+	selected, err := Select(paths, info)
+	if err != nil {
+		t.Fatalf("Could not select alternates: %v", err)
+	}
+
+	expected := map[string]string{
+		"/m/monitors.conf": "/m/monitors.conf##hostname.laptop",
+		"/m/gitconfig":     "/m/gitconfig##os.linux,class.work",
+	}
+
+	if len(selected) != len(expected) {
+		t.Fatalf("Expected %d selected files, got %d: %v", len(expected), len(selected), selected)
+	}
+
+	for path, source := range expected {
+		if selected[path] != source {
+			t.Fatalf("Expected %s to be deployed from %s, got %s", path, source, selected[path])
+		}
+	}
+}
+
+func TestSelectErrors(t *testing.T) {
+	info := &sysinfo.Info{OS: "linux"}
+
+	invalid := [][]string{
+		{"/m/file##kernel.6"},
+		{"/m/file##os"},
+		{"/m/file##os.linux", "/m/file##o.linux"},
+	}
+
+	for _, paths := range invalid {
+		if _, err := Select(paths, info); err == nil {
+			t.Fatalf("Expected an error when selecting from %v", paths)
+		}
+	}
+}
+
+func TestSelectOrder(t *testing.T) {
+	info := &sysinfo.Info{OS: "linux", Hostname: "laptop"}
+
+	orders := [][]string{
+		{"/m/file##os.linux", "/m/file##hostname.laptop", "/m/file##o.linux"},
+		{"/m/file##os.linux", "/m/file##o.linux", "/m/file##hostname.laptop"},
+	}
+
+	for _, paths := range orders {
+		selected, err := Select(paths, info)
+		if err != nil {
+			t.Fatalf("Could not select alternates from %v: %v", paths, err)
+		}
+		if selected["/m/file"] != "/m/file##hostname.laptop" {
+			t.Fatalf("Expected /m/file##hostname.laptop to be selected from %v, got %s", paths, selected["/m/file"])
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package condexpr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mermonia/peridot/internal/sysinfo"
+)
+
+func testEnv() *Env {
+	environment := map[string]string{"WAYLAND_DISPLAY": "wayland-1"}
+
+	return &Env{
+		System: &sysinfo.Info{OS: "linux", Arch: "amd64", Hostname: "laptop-home"},
+		LookupEnv: func(key string) (string, bool) {
+			value, exists := environment[key]
+			return value, exists
+		},
+		Run: func(command string) bool {
+			return command == "true"
+		},
+		Variables: map[string]any{"enable_gpu": true, "theme": "dark", "gpus": []any{}},
+	}
+}
+
+func TestEval(t *testing.T) {
+	cases := map[string]bool{
+		`os("linux")`:  true,
+		`os("darwin")`: false,
+		`os("linux") and (hostname("laptop-*") or env("WAYLAND_DISPLAY"))`: true,
+		`not hostname("work-*")`:                     true,
+		`!os("linux") || arch("amd64")`:              true,
+		`os("linux") && not env("DISPLAY")`:          true,
+		`env("WAYLAND_DISPLAY", "wayland-*")`:        true,
+		`cmd("true") and not cmd("false")`:           true,
+		`var("enable_gpu") and var("theme", "dark")`: true,
+		`var("missing")`:                             false,
+		`var("gpus")`:                                false,
+		`true and false or true`:                     true,
+		`true and (false or false)`:                  false,
+		`not not true`:                               true,
+	}
+
+	for src, expected := range cases {
+		expr, err := Parse(src)
+		if err != nil {
+			t.Fatalf("Could not parse %s: %v", src, err)
+		}
+
+		if result := expr.Eval(testEnv()); result != expected {
+			t.Fatalf("Expected %s to evaluate to %t, got %t", src, expected, result)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]int{
+		``:                          1,
+		`os("linux") and`:           16,
+		`(os("linux")`:              13,
+		`os("linux") hostname("x")`: 13,
+		`kernel("6.*")`:             1,
+		`os()`:                      1,
+		`os("linux`:                 4,
+		`os(linux)`:                 4,
+		`os("linux") & arch("x")`:   13,
+	}
+
+	for src, pos := range cases {
+		_, err := Parse(src)
+
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Fatalf("Expected a syntax error for %q, got %v", src, err)
+		}
+
+		if syntaxErr.Pos != pos {
+			t.Fatalf("Expected error for %q at column %d, got %d (%v)", src, pos, syntaxErr.Pos, err)
+		}
+	}
+}
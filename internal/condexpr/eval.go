@@ -0,0 +1,110 @@
+package condexpr
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mermonia/peridot/internal/sysinfo"
+)
+
+// Env holds everything an expression can inspect while being evaluated.
+type Env struct {
+	System    *sysinfo.Info
+	LookupEnv func(key string) (string, bool)
+	Run       func(command string) bool
+	Variables map[string]any
+}
+
+type literalExpr bool
+
+func (e literalExpr) Eval(env *Env) bool {
+	return bool(e)
+}
+
+type notExpr struct {
+	operand Expr
+}
+
+func (e *notExpr) Eval(env *Env) bool {
+	return !e.operand.Eval(env)
+}
+
+type andExpr struct {
+	left, right Expr
+}
+
+func (e *andExpr) Eval(env *Env) bool {
+	return e.left.Eval(env) && e.right.Eval(env)
+}
+
+type orExpr struct {
+	left, right Expr
+}
+
+func (e *orExpr) Eval(env *Env) bool {
+	return e.left.Eval(env) || e.right.Eval(env)
+}
+
+type callExpr struct {
+	name string
+	args []string
+}
+
+func (e *callExpr) Eval(env *Env) bool {
+	switch e.name {
+	case "os":
+		return match(e.args[0], env.System.OS)
+	case "arch":
+		return match(e.args[0], env.System.Arch)
+	case "hostname":
+		return match(e.args[0], env.System.Hostname)
+	case "env":
+		value, exists := env.LookupEnv(e.args[0])
+		if len(e.args) == 1 {
+			return exists
+		}
+		return exists && match(e.args[1], value)
+	case "cmd":
+		return env.Run(e.args[0])
+	case "var":
+		value, exists := env.Variables[e.args[0]]
+		if len(e.args) == 1 {
+			return exists && isTruthy(value)
+		}
+		return exists && match(e.args[1], fmt.Sprint(value))
+	}
+
+	return false
+}
+
+func match(pattern, value string) bool {
+	matched, err := filepath.Match(pattern, value)
+	return err == nil && matched
+}
+
+// isTruthy mimics the truthiness of template conditionals, while also
+// treating common spellings of false in strings as false.
+func isTruthy(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		switch strings.ToLower(v) {
+		case "", "0", "false", "no", "off":
+			return false
+		}
+		return true
+	case []any:
+		return len(v) > 0
+	case map[string]any:
+		return len(v) > 0
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package condexpr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenComma
+	tokenAnd
+	tokenOr
+	tokenNot
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	// 1-based column of the first character of the token
+	pos int
+}
+
+// SyntaxError is returned when an expression cannot be parsed. Pos is the
+// 1-based column at which the problem was found.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Pos, e.Msg)
+}
+
+func tokenize(src string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(src)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, value: "(", pos: pos})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, value: ")", pos: pos})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{kind: tokenComma, value: ",", pos: pos})
+			i++
+		case r == '!':
+			tokens = append(tokens, token{kind: tokenNot, value: "!", pos: pos})
+			i++
+		case r == '&' || r == '|':
+			if i+1 >= len(runes) || runes[i+1] != r {
+				return nil, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("unexpected character %q", r)}
+			}
+
+			kind := tokenAnd
+			if r == '|' {
+				kind = tokenOr
+			}
+			tokens = append(tokens, token{kind: kind, value: string([]rune{r, r}), pos: pos})
+			i += 2
+		case r == '"' || r == '\'':
+			value, n, err := readString(runes[i:], pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value, pos: pos})
+			i += n
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+
+			word := string(runes[start:i])
+			switch strings.ToLower(word) {
+			case "and":
+				tokens = append(tokens, token{kind: tokenAnd, value: word, pos: pos})
+			case "or":
+				tokens = append(tokens, token{kind: tokenOr, value: word, pos: pos})
+			case "not":
+				tokens = append(tokens, token{kind: tokenNot, value: word, pos: pos})
+			default:
+				tokens = append(tokens, token{kind: tokenIdent, value: word, pos: pos})
+			}
+		default:
+			return nil, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(runes) + 1})
+	return tokens, nil
+}
+
+// readString reads a quoted string starting at runes[0], returning its
+// unescaped value and the number of runes consumed.
+func readString(runes []rune, pos int) (string, int, error) {
+	quote := runes[0]
+	var sb strings.Builder
+
+	for i := 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				sb.WriteRune(runes[i])
+			}
+		case quote:
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteRune(runes[i])
+		}
+	}
+
+	return "", 0, &SyntaxError{Pos: pos, Msg: "unterminated string"}
+}
@@ -0,0 +1,207 @@
+package condexpr
+
+import (
+	"fmt"
+)
+
+// Expr is a parsed condition expression, ready to be evaluated.
+type Expr interface {
+	Eval(env *Env) bool
+}
+
+type predicate struct {
+	minArgs int
+	maxArgs int
+}
+
+// predicates lists the functions that can be called from an expression,
+// along with the number of arguments they accept.
+var predicates = map[string]predicate{
+	"os":       {1, 1},
+	"arch":     {1, 1},
+	"hostname": {1, 1},
+	"env":      {1, 2},
+	"cmd":      {1, 1},
+	"var":      {1, 2},
+}
+
+// Parse compiles the source of an expression such as
+//
+//	os("linux") and (hostname("laptop-*") or env("WAYLAND_DISPLAY"))
+//
+// Operators, in increasing order of precedence, are "or" (||), "and" (&&)
+// and "not" (!). Parentheses can be used for grouping.
+func Parse(src string) (Expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, &SyntaxError{Pos: p.peek().pos, Msg: "empty expression"}
+	}
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.value)}
+	}
+
+	return expr, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("expected %s, found %s", what, describe(tok))}
+	}
+	return tok, nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orExpr{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andExpr{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.peek().kind == tokenNot {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{operand: operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenLParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case tokenIdent:
+		switch tok.value {
+		case "true":
+			return literalExpr(true), nil
+		case "false":
+			return literalExpr(false), nil
+		}
+		return p.parseCall(tok)
+	default:
+		return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("expected a predicate, found %s", describe(tok))}
+	}
+}
+
+func (p *parser) parseCall(name token) (Expr, error) {
+	pred, ok := predicates[name.value]
+	if !ok {
+		return nil, &SyntaxError{Pos: name.pos, Msg: fmt.Sprintf("unknown predicate %q", name.value)}
+	}
+
+	if _, err := p.expect(tokenLParen, "'('"); err != nil {
+		return nil, err
+	}
+
+	args := []string{}
+	if p.peek().kind != tokenRParen {
+		for {
+			arg, err := p.expect(tokenString, "a quoted string")
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg.value)
+
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if _, err := p.expect(tokenRParen, "')'"); err != nil {
+		return nil, err
+	}
+
+	if len(args) < pred.minArgs || len(args) > pred.maxArgs {
+		return nil, &SyntaxError{Pos: name.pos, Msg: fmt.Sprintf("%s expects %s, found %d",
+			name.value, describeArity(pred), len(args))}
+	}
+
+	return &callExpr{name: name.value, args: args}, nil
+}
+
+func describe(tok token) string {
+	if tok.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", tok.value)
+}
+
+func describeArity(pred predicate) string {
+	if pred.minArgs == pred.maxArgs {
+		return fmt.Sprintf("%d argument(s)", pred.minArgs)
+	}
+	return fmt.Sprintf("%d to %d arguments", pred.minArgs, pred.maxArgs)
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mermonia/peridot/internal/paths"
+)
+
+// Config is the dotfiles-level configuration, stored in peridot.toml at the
+// root of the dotfiles dir. Unlike module configs, it is optional.
+type Config struct {
+	Variables  map[string]any   `toml:"variables"`
+	Secrets    SecretsConfig    `toml:"secrets"`
+	Encryption EncryptionConfig `toml:"encryption"`
+}
+
+// SecretsConfig configures where the values of the secret template function
+// come from. Default is the name of the provider used when a template does
+// not specify one.
+type SecretsConfig struct {
+	Default   string                    `toml:"default"`
+	Providers map[string]SecretProvider `toml:"providers"`
+}
+
+// SecretProvider is a single source of secrets. Which of the fields apply
+// depends on its Type (see the secrets package).
+type SecretProvider struct {
+	Type    string `toml:"type"`
+	Command string `toml:"command"`
+	Prefix  string `toml:"prefix"`
+	Path    string `toml:"path"`
+}
+
+// EncryptionConfig holds the keys used for encrypted module files. Identity
+// is the path to an age identity (private key) file, which should live
+// outside the dotfiles dir. Files are encrypted to Recipients, or to the
+// public keys of the identity if none are set.
+type EncryptionConfig struct {
+	Identity   string   `toml:"identity"`
+	Recipients []string `toml:"recipients"`
+}
+
+// HostConfig is the configuration specific to a single machine, stored in
+// hosts/<hostname>.toml. It is optional as well.
+type HostConfig struct {
+	Variables map[string]any `toml:"variables"`
+}
+
+func Load(dotfilesDir string) (*Config, error) {
+	c := &Config{}
+	if err := decodeIfExists(paths.GlobalConfigFilePath(dotfilesDir), c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func LoadHost(dotfilesDir, hostname string) (*HostConfig, error) {
+	c := &HostConfig{}
+	if hostname == "" {
+		return c, nil
+	}
+
+	if err := decodeIfExists(paths.HostConfigFilePath(dotfilesDir, hostname), c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func decodeIfExists(path string, v any) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("could not stat config file %s: %w", path, err)
+	}
+
+	if _, err := toml.DecodeFile(path, v); err != nil {
+		return fmt.Errorf("could not decode config file %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/mermonia/peridot/internal/alternate"
+	"github.com/mermonia/peridot/internal/config"
+	"github.com/mermonia/peridot/internal/paths"
+)
+
+// Suffix marks encrypted module files, as in "ssh/config.age". Alternate
+// suffixes go after it, as in "ssh/config.age##class.work".
+const Suffix = ".age"
+
+// Keys are the age keys used to encrypt and decrypt module files.
+type Keys struct {
+	identityPath string
+	identities   []age.Identity
+	recipients   []age.Recipient
+}
+
+// Load reads the keys configured in the [encryption] section of peridot.toml.
+func Load(dotfilesDir string) (*Keys, error) {
+	cfg, err := config.Load(dotfilesDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not load global config: %w", err)
+	}
+
+	return New(dotfilesDir, cfg.Encryption)
+}
+
+func New(dotfilesDir string, cfg config.EncryptionConfig) (*Keys, error) {
+	if cfg.Identity == "" {
+		return nil, fmt.Errorf("no identity is configured in the [encryption] section of %s",
+			paths.GlobalConfigFileName)
+	}
+
+	identityPath, err := paths.ResolvePath(cfg.Identity, dotfilesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(identityPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open identity file (it can be created with age-keygen -o %s): %w",
+			identityPath, err)
+	}
+	defer f.Close()
+
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse identity file %s: %w", identityPath, err)
+	}
+
+	k := &Keys{
+		identityPath: identityPath,
+		identities:   identities,
+	}
+
+	if len(cfg.Recipients) > 0 {
+		k.recipients, err = age.ParseRecipients(strings.NewReader(strings.Join(cfg.Recipients, "\n")))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse recipients: %w", err)
+		}
+		return k, nil
+	}
+
+	for _, identity := range identities {
+		if x25519, ok := identity.(*age.X25519Identity); ok {
+			k.recipients = append(k.recipients, x25519.Recipient())
+		}
+	}
+
+	return k, nil
+}
+
+// IsEncrypted reports whether the module file at path is encrypted, going by
+// its name.
+func IsEncrypted(path string) bool {
+	base, _, _ := alternate.Split(filepath.Base(path))
+	return strings.HasSuffix(base, Suffix)
+}
+
+// EncryptedPath returns the path the encrypted version of the module file at
+// path should be stored at, keeping any alternate suffix last.
+func EncryptedPath(path string) string {
+	dir, name := filepath.Split(path)
+	base, suffix, isAlternate := alternate.Split(name)
+	if strings.HasSuffix(base, Suffix) {
+		return path
+	}
+
+	if isAlternate {
+		return filepath.Join(dir, base+Suffix+alternate.Separator+suffix)
+	}
+	return filepath.Join(dir, base+Suffix)
+}
+
+// DecryptedPath returns the path an encrypted module file is deployed as,
+// which is the path without the encryption suffix.
+func DecryptedPath(path string) string {
+	return strings.TrimSuffix(path, Suffix)
+}
+
+// Encrypt encrypts src into dst, in the ASCII armored format so that the
+// encrypted files can be diffed and reviewed like any other text file.
+func (k *Keys) Encrypt(dst io.Writer, src io.Reader) error {
+	if len(k.recipients) == 0 {
+		return fmt.Errorf("no recipients to encrypt to, set them in the [encryption] section of %s",
+			paths.GlobalConfigFileName)
+	}
+
+	armorWriter := armor.NewWriter(dst)
+	w, err := age.Encrypt(armorWriter, k.recipients...)
+	if err != nil {
+		return fmt.Errorf("could not encrypt: %w", err)
+	}
+
+	if _, err := io.Copy(w, src); err != nil {
+		return fmt.Errorf("could not encrypt: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("could not encrypt: %w", err)
+	}
+
+	return armorWriter.Close()
+}
+
+// Decrypt decrypts src, either armored or binary, into dst.
+func (k *Keys) Decrypt(dst io.Writer, src io.Reader) error {
+	br := bufio.NewReader(src)
+
+	var in io.Reader = br
+	if header, _ := br.Peek(len(armor.Header)); string(header) == armor.Header {
+		in = armor.NewReader(br)
+	}
+
+	r, err := age.Decrypt(in, k.identities...)
+	if err != nil {
+		return fmt.Errorf("could not decrypt with identity %s: %w", k.identityPath, err)
+	}
+
+	if _, err := io.Copy(dst, r); err != nil {
+		return fmt.Errorf("could not decrypt: %w", err)
+	}
+
+	return nil
+}
+
+// EncryptFile encrypts the file at src into dst.
+func (k *Keys) EncryptFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("could not open file: %w", err)
+	}
+	defer in.Close()
+
+	var encrypted bytes.Buffer
+	if err := k.Encrypt(&encrypted, in); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(dst, encrypted.Bytes(), 0644); err != nil {
+		return fmt.Errorf("could not write encrypted file: %w", err)
+	}
+
+	return nil
+}
+
+// DecryptFile decrypts the file at src into dst, which is only readable by
+// its owner.
+func (k *Keys) DecryptFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("could not open file: %w", err)
+	}
+	defer in.Close()
+
+	// Nothing is written unless decryption succeeds
+	var decrypted bytes.Buffer
+	if err := k.Decrypt(&decrypted, in); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("could not create parent dirs: %w", err)
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("could not create decrypted file: %w", err)
+	}
+	defer out.Close()
+
+	// The file might have existed with a different mode
+	if err := out.Chmod(0600); err != nil {
+		return fmt.Errorf("could not set decrypted file mode: %w", err)
+	}
+
+	if _, err := decrypted.WriteTo(out); err != nil {
+		return fmt.Errorf("could not write decrypted file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package crypt
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/mermonia/peridot/internal/config"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	dir := t.TempDir()
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "key.txt"), []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := New(dir, config.EncryptionConfig{Identity: "key.txt"})
+	if err != nil {
+		t.Fatalf("Could not load keys: %v", err)
+	}
+
+	var encrypted, decrypted bytes.Buffer
+	if err := keys.Encrypt(&encrypted, strings.NewReader("Host internal\n")); err != nil {
+		t.Fatalf("Could not encrypt: %v", err)
+	}
+
+	if strings.Contains(encrypted.String(), "internal") {
+		t.Fatalf("Expected the encrypted output not to contain the plaintext")
+	}
+
+	if err := keys.Decrypt(&decrypted, &encrypted); err != nil {
+		t.Fatalf("Could not decrypt: %v", err)
+	}
+
+	if decrypted.String() != "Host internal\n" {
+		t.Fatalf("Expected the decrypted output to match the plaintext, got %q", decrypted.String())
+	}
+}
+
+func TestEncryptedPath(t *testing.T) {
+	tests := map[string]string{
+		"ssh/config":              "ssh/config.age",
+		"ssh/config.age":          "ssh/config.age",
+		"ssh/config##class.work":  "ssh/config.age##class.work",
+		"ssh/config.age##h.my-vm": "ssh/config.age##h.my-vm",
+	}
+
+	for path, expected := range tests {
+		if got := EncryptedPath(path); got != expected {
+			t.Fatalf("Expected the encrypted path of %s to be %s, got %s", path, expected, got)
+		}
+
+		if !IsEncrypted(expected) {
+			t.Fatalf("Expected %s to be encrypted", expected)
+		}
+	}
+}
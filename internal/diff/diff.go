@@ -0,0 +1,150 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ContextLines is the number of unchanged lines shown around every change.
+const ContextLines = 3
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	line string
+	// Line numbers (0-based) in the old and new text before the operation
+	oldLine, newLine int
+}
+
+// Unified returns the differences between the old and new texts in the
+// unified diff format, or an empty string if they are equal.
+func Unified(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+
+	for start := 0; start < len(ops); {
+		// Find the next change, then extend the hunk until there is a run
+		// of unchanged lines long enough to separate it from the next one
+		first := start
+		for first < len(ops) && ops[first].kind == opEqual {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+
+		last := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != opEqual {
+				last = i
+			} else if i-last > 2*ContextLines {
+				break
+			}
+		}
+
+		from := max(first-ContextLines, start)
+		to := min(last+ContextLines+1, len(ops))
+		writeHunk(&b, ops[from:to])
+		start = to
+	}
+
+	return b.String()
+}
+
+func writeHunk(b *strings.Builder, ops []op) {
+	oldCount, newCount := 0, 0
+	for _, o := range ops {
+		if o.kind != opInsert {
+			oldCount++
+		}
+		if o.kind != opDelete {
+			newCount++
+		}
+	}
+
+	fmt.Fprintf(b, "@@ -%s +%s @@\n",
+		hunkRange(ops[0].oldLine, oldCount), hunkRange(ops[0].newLine, newCount))
+
+	for _, o := range ops {
+		switch o.kind {
+		case opEqual:
+			b.WriteString(" ")
+		case opDelete:
+			b.WriteString("-")
+		case opInsert:
+			b.WriteString("+")
+		}
+		b.WriteString(o.line)
+		b.WriteString("\n")
+	}
+}
+
+// hunkRange formats the start and length of a hunk, which are 1-based except
+// for empty ranges.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines computes the operations turning a into b through their longest
+// common subsequence. Dotfiles are small, so the quadratic table is fine.
+func diffLines(a, b []string) []op {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]op, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, op{opEqual, a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{opDelete, a[i], i, j})
+			i++
+		default:
+			ops = append(ops, op{opInsert, b[j], i, j})
+			j++
+		}
+	}
+
+	return ops
+}
@@ -0,0 +1,34 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		old, new string
+		expected string
+	}{
+		{old: "a\nb\n", new: "a\nb\n", expected: ""},
+		{
+			old:      "a\nb\nc\n",
+			new:      "a\nB\nc\n",
+			expected: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			old:      "",
+			new:      "x\n",
+			expected: "--- old\n+++ new\n@@ -0,0 +1 @@\n+x\n",
+		},
+		{
+			old: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			new: "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			expected: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n" +
+				"@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+	}
+
+	for _, test := range tests {
+		if got := Unified("old", "new", test.old, test.new); got != test.expected {
+			t.Fatalf("Expected the diff of %q and %q to be:\n%s\ngot:\n%s", test.old, test.new, test.expected, got)
+		}
+	}
+}
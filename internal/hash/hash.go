@@ -2,6 +2,7 @@ package hash
 
 import (
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -21,3 +22,14 @@ func HashFile(path string) (string, error) {
 
 	return fmt.Sprintf("%x", hash.Sum(nil)), nil
 }
+
+// HashValue hashes the JSON encoding of a value, which is deterministic since
+// the keys of maps are sorted.
+func HashValue(value any) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("could not encode the value: %w", err)
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(encoded)), nil
+}
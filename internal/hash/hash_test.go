@@ -42,3 +42,28 @@ func TestHash(t *testing.T) {
 		}
 	}
 }
+
+func TestHashValue(t *testing.T) {
+	first, err := HashValue(map[string]any{"a": 1, "b": map[string]any{"c": "d", "e": []any{1, 2}}})
+	if err != nil {
+		t.Fatalf("Could not hash value: %v", err)
+	}
+
+	second, err := HashValue(map[string]any{"b": map[string]any{"e": []any{1, 2}, "c": "d"}, "a": 1})
+	if err != nil {
+		t.Fatalf("Could not hash value: %v", err)
+	}
+
+	if first != second {
+		t.Fatalf("Expected equal values to have the same hash, got %s and %s", first, second)
+	}
+
+	third, err := HashValue(map[string]any{"a": 2, "b": map[string]any{"c": "d", "e": []any{1, 2}}})
+	if err != nil {
+		t.Fatalf("Could not hash value: %v", err)
+	}
+
+	if first == third {
+		t.Fatalf("Expected different values to have different hashes")
+	}
+}
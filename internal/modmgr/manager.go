@@ -4,12 +4,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/mermonia/peridot/internal/appcontext"
+	"github.com/mermonia/peridot/internal/crypt"
 	"github.com/mermonia/peridot/internal/logger"
 	"github.com/mermonia/peridot/internal/module"
 	"github.com/mermonia/peridot/internal/paths"
+	"github.com/mermonia/peridot/internal/secrets"
 	"github.com/mermonia/peridot/internal/state"
+	"github.com/mermonia/peridot/internal/sysinfo"
 	"github.com/mermonia/peridot/internal/templating"
 )
 
@@ -57,17 +61,83 @@ func RemoveModule(moduleName string, appCtx *appcontext.Context) error {
 		return nil
 	}
 
+	// The deployed files are replaced by renderings of their sources, which
+	// would silently discard any edit made to them
+	if modified := moduleState.ModifiedTargets(); len(modified) > 0 {
+		return fmt.Errorf("the deployed files %s were modified since they were deployed, run 'peridot pull %s' "+
+			"to keep the changes or 'peridot deploy %s --overwrite' to discard them before removing the module",
+			strings.Join(modified, ", "), moduleName, moduleName)
+	}
+
 	mod, err := module.Load(appCtx.DotfilesDir, moduleName, moduleState)
 	if err != nil {
 		return fmt.Errorf("could not load module: %w", err)
 	}
 
+	if _, err := mod.ResolveVariables(appCtx.DotfilesDir, sysinfo.Current().Hostname, nil); err != nil {
+		return err
+	}
+
+	moduleDir := paths.ModuleDir(appCtx.DotfilesDir, moduleName)
+	partials, err := templating.FindPartials(paths.SharedPartialsDir(appCtx.DotfilesDir),
+		paths.PartialsDir(appCtx.DotfilesDir, moduleName))
+	if err != nil {
+		return err
+	}
+
+	secretStore, err := secrets.Load(appCtx.DotfilesDir)
+	if err != nil {
+		return fmt.Errorf("could not load secret providers: %w", err)
+	}
+
+	templateOpts := &templating.Options{
+		DotfilesDir: appCtx.DotfilesDir,
+		Module:      moduleName,
+		ModuleDir:   moduleDir,
+		Root:        mod.Config.Root,
+		Variables:   mod.Variables,
+		LeftDelim:   mod.Config.Template.LeftDelim,
+		RightDelim:  mod.Config.Template.RightDelim,
+		Partials:    partials,
+		Strict:      mod.Config.Template.IsStrict(),
+		Secrets:     secretStore,
+	}
+
+	var keys *crypt.Keys
+	for path := range moduleState.Files {
+		if crypt.IsEncrypted(path) {
+			if keys, err = crypt.Load(appCtx.DotfilesDir); err != nil {
+				return fmt.Errorf("could not load encryption keys: %w", err)
+			}
+			break
+		}
+	}
+
 	for path, entry := range moduleState.Files {
 		if err := removeIfSymlink(entry.SymlinkPath); err != nil {
 			return err
 		}
 
-		if err := templating.CreateRenderedFile(path, entry.SymlinkPath, mod.Config.TemplateVariables); err != nil {
+		if crypt.IsEncrypted(path) {
+			if err := keys.DecryptFile(path, entry.SymlinkPath); err != nil {
+				return fmt.Errorf("could not decrypt file: %w", err)
+			}
+			continue
+		}
+
+		var fileOpts *templating.Options
+		if entry.Template {
+			opts := *templateOpts
+			opts.Target = entry.SymlinkPath
+			// The root might have come from --root, and states written by
+			// older versions do not record it
+			if entry.Root != "" {
+				opts.Root = entry.Root
+			}
+			fileOpts = &opts
+		}
+
+		if _, err := templating.CreateRenderedFile(path, entry.SymlinkPath, fileOpts); err != nil {
 			return fmt.Errorf("could not create rendered file: %w", err)
 		}
 	}
@@ -0,0 +1,102 @@
+package module
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mermonia/peridot/internal/condexpr"
+	"github.com/mermonia/peridot/internal/sysinfo"
+	"github.com/mermonia/peridot/internal/utils"
+)
+
+// Check returns an error describing the first condition that the given
+// machine does not fulfill, or nil if all of them are met. The variables are
+// only used by the when expression.
+func (c *Conditions) Check(info *sysinfo.Info, variables map[string]any) error {
+	requiredOs := strings.ToLower(c.OperatingSystem)
+	if requiredOs != "" && requiredOs != info.OS {
+		return fmt.Errorf("requires os to be %s, found %s", requiredOs, info.OS)
+	}
+
+	checks := []struct {
+		name    string
+		allowed StringList
+		current []string
+	}{
+		{"hostname", c.Hostname, []string{info.Hostname}},
+		{"arch", c.Arch, []string{info.Arch}},
+		{"distro", c.Distro, []string{info.Distro}},
+		{"distro_like", c.DistroLike, append([]string{info.Distro}, info.DistroLike...)},
+		{"user", c.User, []string{info.Username}},
+		{"shell", c.Shell, []string{info.Shell}},
+	}
+
+	for _, check := range checks {
+		if !check.allowed.Matches(check.current...) {
+			return fmt.Errorf("requires %s to match [%s], found %s", check.name,
+				strings.Join(check.allowed.nonEmpty(), ", "), strings.Join(check.current, " "))
+		}
+	}
+
+	for _, path := range c.FileExists {
+		if path == "" {
+			continue
+		}
+
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("requires file %s to exist", path)
+		}
+	}
+
+	for _, envvar := range c.EnvRequired {
+		if _, exists := os.LookupEnv(envvar); envvar != "" && !exists {
+			return fmt.Errorf("requires environment variable %s to be set", envvar)
+		}
+	}
+
+	if c.CheckCommand != "" && !utils.CommandSucceeds(c.CheckCommand) {
+		return fmt.Errorf("requires the check command to succeed: %s", c.CheckCommand)
+	}
+
+	if c.when != nil {
+		env := &condexpr.Env{
+			System:    info,
+			LookupEnv: os.LookupEnv,
+			Run:       utils.CommandSucceeds,
+			Variables: variables,
+		}
+
+		if !c.when.Eval(env) {
+			return fmt.Errorf("requires the when expression to be true: %s", c.When)
+		}
+	}
+
+	return nil
+}
+
+// Matches reports whether any of the values matches any of the glob patterns
+// in the list. Empty patterns are ignored, and a list without patterns
+// matches everything.
+func (l StringList) Matches(values ...string) bool {
+	patterns := l.nonEmpty()
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		for _, value := range values {
+			if matched, err := filepath.Match(pattern, value); err == nil && matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (l StringList) nonEmpty() []string {
+	return slices.DeleteFunc(slices.Clone(l), func(s string) bool { return s == "" })
+}
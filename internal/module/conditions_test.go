@@ -0,0 +1,80 @@
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mermonia/peridot/internal/sysinfo"
+)
+
+func TestCheckConditions(t *testing.T) {
+	info := &sysinfo.Info{
+		OS:         "linux",
+		Arch:       "amd64",
+		Hostname:   "laptop-home",
+		Username:   "me",
+		Shell:      "zsh",
+		Distro:     "ubuntu",
+		DistroLike: []string{"debian"},
+	}
+
+	existing := filepath.Join(t.TempDir(), "exists")
+	if err := os.WriteFile(existing, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name       string
+		conditions Conditions
+		eligible   bool
+	}{
+		{"none", Conditions{}, true},
+		{"os", Conditions{OperatingSystem: "Linux"}, true},
+		{"other os", Conditions{OperatingSystem: "darwin"}, false},
+		{"hostname glob", Conditions{Hostname: StringList{"laptop-*"}}, true},
+		{"hostname list", Conditions{Hostname: StringList{"desktop", "laptop-home"}}, true},
+		{"other hostname", Conditions{Hostname: StringList{"work-*"}}, false},
+		{"empty patterns", Conditions{Hostname: StringList{""}}, true},
+		{"arch", Conditions{Arch: StringList{"amd64", "arm64"}}, true},
+		{"other arch", Conditions{Arch: StringList{"arm*"}}, false},
+		{"distro", Conditions{Distro: StringList{"ubuntu"}}, true},
+		{"other distro", Conditions{Distro: StringList{"debian"}}, false},
+		{"distro like", Conditions{DistroLike: StringList{"debian"}}, true},
+		{"distro like itself", Conditions{DistroLike: StringList{"ubuntu"}}, true},
+		{"user", Conditions{User: StringList{"m?"}}, true},
+		{"other user", Conditions{User: StringList{"root"}}, false},
+		{"shell", Conditions{Shell: StringList{"*sh"}}, true},
+		{"other shell", Conditions{Shell: StringList{"fish"}}, false},
+		{"file exists", Conditions{FileExists: []string{existing, ""}}, true},
+		{"file missing", Conditions{FileExists: []string{existing + ".missing"}}, false},
+	}
+
+	for _, c := range cases {
+		if err := c.conditions.Check(info, nil); (err == nil) != c.eligible {
+			t.Fatalf("Expected eligibility of %q to be %t, got error %v", c.name, c.eligible, err)
+		}
+	}
+}
+
+func TestStringList(t *testing.T) {
+	var conditions Conditions
+
+	if _, err := toml.Decode("hostname = \"laptop-*\"\narch = [\"amd64\", \"arm64\"]\n", &conditions); err != nil {
+		t.Fatalf("Could not decode conditions: %v", err)
+	}
+
+	if len(conditions.Hostname) != 1 || conditions.Hostname[0] != "laptop-*" {
+		t.Fatalf("Expected a single hostname pattern, got %v", conditions.Hostname)
+	}
+	if len(conditions.Arch) != 2 || conditions.Arch[1] != "arm64" {
+		t.Fatalf("Expected two arch patterns, got %v", conditions.Arch)
+	}
+
+	for _, invalid := range []string{"user = 1", "user = [\"me\", 2]"} {
+		if _, err := toml.Decode(invalid, &conditions); err == nil {
+			t.Fatalf("Expected an error when decoding %q", invalid)
+		}
+	}
+}
@@ -2,6 +2,9 @@ package module
 
 import (
 	_ "embed"
+	"fmt"
+
+	"github.com/mermonia/peridot/internal/condexpr"
 )
 
 //go:embed default-module.toml
@@ -11,15 +14,30 @@ type Config struct {
 	Root               string            `toml:"root"`
 	Ignore             []string          `toml:"ignore"`
 	Dependencies       []string          `toml:"dependencies"`
+	VersionProbes      map[string]string `toml:"version_probes"`
 	ModuleDependencies []string          `toml:"module_dependencies"`
+	VarsFiles          []string          `toml:"vars_files"`
 	Conditions         Conditions        `toml:"conditions"`
 	Hooks              Hooks             `toml:"hooks"`
-	TemplateVariables  map[string]string `toml:"variables"`
+	Template           TemplateConfig    `toml:"template"`
+	TemplateVariables  map[string]any    `toml:"variables"`
 }
 
 type Conditions struct {
-	OperatingSystem string   `toml:"os"`
-	EnvRequired     []string `toml:"env_exists"`
+	OperatingSystem string     `toml:"os"`
+	Hostname        StringList `toml:"hostname"`
+	Arch            StringList `toml:"arch"`
+	Distro          StringList `toml:"distro"`
+	DistroLike      StringList `toml:"distro_like"`
+	User            StringList `toml:"user"`
+	Shell           StringList `toml:"shell"`
+	FileExists      []string   `toml:"file_exists"`
+	EnvRequired     []string   `toml:"env_exists"`
+	CheckCommand    string     `toml:"check"`
+	When            string     `toml:"when"`
+
+	// when is the compiled form of When, set by LoadConfig
+	when condexpr.Expr
 }
 
 type Hooks struct {
@@ -28,6 +46,31 @@ type Hooks struct {
 	PostRemove string `toml:"post_remove"`
 }
 
+// StringList accepts either a single string or a list of strings, so that
+// simple conditions can be written as `hostname = "laptop-*"`.
+type StringList []string
+
+func (l *StringList) UnmarshalTOML(data any) error {
+	switch value := data.(type) {
+	case string:
+		*l = StringList{value}
+	case []any:
+		list := make(StringList, 0, len(value))
+		for _, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("expected a list of strings, found %T", item)
+			}
+			list = append(list, s)
+		}
+		*l = list
+	default:
+		return fmt.Errorf("expected a string or a list of strings, found %T", data)
+	}
+
+	return nil
+}
+
 type PathField struct {
 	Name  string
 	Value *string
@@ -49,21 +92,77 @@ func (mCfg *Config) DeepCopy() *Config {
 		Ignore:             append([]string{}, mCfg.Ignore...),
 		Dependencies:       append([]string{}, mCfg.Dependencies...),
 		ModuleDependencies: append([]string{}, mCfg.ModuleDependencies...),
+		VarsFiles:          append([]string{}, mCfg.VarsFiles...),
 		Conditions: Conditions{
 			OperatingSystem: mCfg.Conditions.OperatingSystem,
+			Hostname:        append(StringList{}, mCfg.Conditions.Hostname...),
+			Arch:            append(StringList{}, mCfg.Conditions.Arch...),
+			Distro:          append(StringList{}, mCfg.Conditions.Distro...),
+			DistroLike:      append(StringList{}, mCfg.Conditions.DistroLike...),
+			User:            append(StringList{}, mCfg.Conditions.User...),
+			Shell:           append(StringList{}, mCfg.Conditions.Shell...),
+			FileExists:      append([]string{}, mCfg.Conditions.FileExists...),
 			EnvRequired:     append([]string{}, mCfg.Conditions.EnvRequired...),
+			CheckCommand:    mCfg.Conditions.CheckCommand,
+			When:            mCfg.Conditions.When,
+			when:            mCfg.Conditions.when,
 		},
 		Hooks: Hooks{
 			PreDeploy:  mCfg.Hooks.PreDeploy,
 			PostDeploy: mCfg.Hooks.PostDeploy,
 			PostRemove: mCfg.Hooks.PostRemove,
 		},
-		TemplateVariables: make(map[string]string),
+		Template: TemplateConfig{
+			Mode:       mCfg.Template.Mode,
+			Include:    append([]string{}, mCfg.Template.Include...),
+			Exclude:    append([]string{}, mCfg.Template.Exclude...),
+			LeftDelim:  mCfg.Template.LeftDelim,
+			RightDelim: mCfg.Template.RightDelim,
+		},
+		VersionProbes:     make(map[string]string),
+		TemplateVariables: deepCopyMap(mCfg.TemplateVariables),
 	}
 
-	for k, v := range mCfg.TemplateVariables {
-		newMCfg.TemplateVariables[k] = v
+	if mCfg.Template.Strict != nil {
+		strict := *mCfg.Template.Strict
+		newMCfg.Template.Strict = &strict
+	}
+
+	for k, v := range mCfg.VersionProbes {
+		newMCfg.VersionProbes[k] = v
 	}
 
 	return newMCfg
 }
+
+// deepCopyMap copies a map decoded from TOML, including any nested tables
+// and arrays, so that the copy shares no mutable state with the original.
+func deepCopyMap(m map[string]any) map[string]any {
+	newMap := make(map[string]any, len(m))
+	for k, v := range m {
+		newMap[k] = deepCopyValue(v)
+	}
+	return newMap
+}
+
+func deepCopyValue(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		return deepCopyMap(value)
+	case []map[string]any:
+		newSlice := make([]map[string]any, len(value))
+		for i, m := range value {
+			newSlice[i] = deepCopyMap(m)
+		}
+		return newSlice
+	case []any:
+		newSlice := make([]any, len(value))
+		for i, item := range value {
+			newSlice[i] = deepCopyValue(item)
+		}
+		return newSlice
+	default:
+		// Strings, numbers, booleans and dates are immutable values
+		return value
+	}
+}
@@ -0,0 +1,141 @@
+package module
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultVersionProbe are the arguments passed to a binary in order to find
+// out its version, unless configured otherwise in [version_probes].
+const DefaultVersionProbe = "--version"
+
+// Dependency is a required binary, optionally constrained to a range of
+// versions, as in "nvim >= 0.10".
+type Dependency struct {
+	Binary   string
+	Operator string
+	Version  string
+}
+
+var (
+	dependencyRegexp = regexp.MustCompile(`^\s*([^\s<>=!]+)\s*(>=|<=|==|!=|=|>|<)\s*(\S+)\s*$`)
+	versionRegexp    = regexp.MustCompile(`\d+(\.\d+)*`)
+)
+
+func ParseDependency(s string) (*Dependency, error) {
+	if !strings.ContainsAny(s, "<>=!") {
+		return &Dependency{Binary: strings.TrimSpace(s)}, nil
+	}
+
+	matches := dependencyRegexp.FindStringSubmatch(s)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid dependency %q, expected \"<binary> <operator> <version>\"", s)
+	}
+
+	if !versionRegexp.MatchString(matches[3]) {
+		return nil, fmt.Errorf("invalid version %q in dependency %q", matches[3], s)
+	}
+
+	return &Dependency{Binary: matches[1], Operator: matches[2], Version: matches[3]}, nil
+}
+
+func (d *Dependency) String() string {
+	if d.Operator == "" {
+		return d.Binary
+	}
+	return fmt.Sprintf("%s %s %s", d.Binary, d.Operator, d.Version)
+}
+
+// Check looks for the binary in the PATH and, if the dependency has a version
+// constraint, runs the binary with the given probe arguments and compares the
+// first version number found in its output.
+func (d *Dependency) Check(probe string) error {
+	if d.Binary == "" {
+		return nil
+	}
+
+	path, err := exec.LookPath(d.Binary)
+	if err != nil {
+		return fmt.Errorf("%s: not found", d.Binary)
+	}
+
+	if d.Operator == "" {
+		return nil
+	}
+
+	if probe == "" {
+		probe = DefaultVersionProbe
+	}
+
+	output, err := exec.Command(path, strings.Fields(probe)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: could not probe version with %q: %v", d.Binary, probe, err)
+	}
+
+	found := ExtractVersion(string(output))
+	if found == "" {
+		return fmt.Errorf("%s: no version found in the output of %q, requires %s %s",
+			d.Binary, probe, d.Operator, d.Version)
+	}
+
+	if !versionSatisfies(CompareVersions(found, d.Version), d.Operator) {
+		return fmt.Errorf("%s: found %s, requires %s %s", d.Binary, found, d.Operator, d.Version)
+	}
+
+	return nil
+}
+
+// ExtractVersion returns the first dotted version number (e.g. 0.10.2) that
+// appears in s, or an empty string if there is none.
+func ExtractVersion(s string) string {
+	return versionRegexp.FindString(s)
+}
+
+// CompareVersions compares two dotted version numbers component by component,
+// treating missing components as zeros. Anything after the numeric part of a
+// version (e.g. "-dev") is ignored.
+func CompareVersions(a, b string) int {
+	as := strings.Split(ExtractVersion(a), ".")
+	bs := strings.Split(ExtractVersion(b), ".")
+
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func versionSatisfies(cmp int, operator string) bool {
+	switch operator {
+	case ">=":
+		return cmp >= 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	case "<":
+		return cmp < 0
+	case "=", "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	}
+
+	return false
+}
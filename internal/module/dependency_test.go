@@ -0,0 +1,51 @@
+package module
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"0.10", "0.9.5", 1},
+		{"0.9.5", "0.10", -1},
+		{"0.10.0", "0.10", 0},
+		{"NVIM v0.10.1-dev", "0.10.1", 0},
+		{"1.2", "1.2.1", -1},
+		{"2", "10", -1},
+	}
+
+	for _, c := range cases {
+		if result := CompareVersions(c.a, c.b); result != c.expected {
+			t.Fatalf("Expected CompareVersions(%q, %q) to be %d, got %d", c.a, c.b, c.expected, result)
+		}
+	}
+}
+
+func TestParseDependency(t *testing.T) {
+	valid := map[string]Dependency{
+		"git":           {Binary: "git"},
+		"nvim >= 0.10":  {Binary: "nvim", Operator: ">=", Version: "0.10"},
+		"hyprctl<0.40":  {Binary: "hyprctl", Operator: "<", Version: "0.40"},
+		" tmux == 3.4 ": {Binary: "tmux", Operator: "==", Version: "3.4"},
+		"zsh != 5.8.1":  {Binary: "zsh", Operator: "!=", Version: "5.8.1"},
+	}
+
+	for s, expected := range valid {
+		dep, err := ParseDependency(s)
+		if err != nil {
+			t.Fatalf("Could not parse dependency %q: %v", s, err)
+		}
+
+		if *dep != expected {
+			t.Fatalf("Expected %q to be parsed as %+v, got %+v", s, expected, *dep)
+		}
+	}
+
+	invalid := []string{"nvim >=", ">= 0.10", "nvim >= latest", "nvim => 0.10"}
+	for _, s := range invalid {
+		if _, err := ParseDependency(s); err == nil {
+			t.Fatalf("Expected an error when parsing dependency %q", s)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 
 	"github.com/BurntSushi/toml"
+	"github.com/mermonia/peridot/internal/condexpr"
 	"github.com/mermonia/peridot/internal/paths"
 )
 
@@ -35,16 +36,47 @@ func (c *Config) resolvePaths(base string) error {
 	pathFields := c.GetPathFields()
 
 	for _, field := range pathFields {
-		resolved, err := paths.ResolvePath(*field.Value, base)
+		resolved, err := resolvePath(*field.Value, base)
 		if err != nil {
 			return fmt.Errorf("could not resolve path field %s: %w", field.Name, err)
 		}
 		*field.Value = resolved
 	}
 
+	for i, path := range c.Conditions.FileExists {
+		if path == "" {
+			continue
+		}
+
+		resolved, err := resolvePath(path, base)
+		if err != nil {
+			return fmt.Errorf("could not resolve file_exists condition %s: %w", path, err)
+		}
+		c.Conditions.FileExists[i] = resolved
+	}
+
+	for i, path := range c.VarsFiles {
+		resolved, err := resolvePath(path, base)
+		if err != nil {
+			return fmt.Errorf("could not resolve vars file %s: %w", path, err)
+		}
+		c.VarsFiles[i] = resolved
+	}
+
 	return nil
 }
 
+// resolvePath expands the environment variables in a path from the config
+// (see paths.ExpandEnv), then resolves it against the module dir.
+func resolvePath(path, base string) (string, error) {
+	expanded, err := paths.ExpandEnv(path)
+	if err != nil {
+		return "", err
+	}
+
+	return paths.ResolvePath(expanded, base)
+}
+
 func (c *Config) validate() error {
 	if err := c.validateRequiredFields(); err != nil {
 		return err
@@ -54,6 +86,28 @@ func (c *Config) validate() error {
 		return err
 	}
 
+	if err := c.compileConditions(); err != nil {
+		return err
+	}
+
+	if err := c.validateDependencies(); err != nil {
+		return err
+	}
+
+	if err := c.Template.validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Config) validateDependencies() error {
+	for _, dep := range c.Dependencies {
+		if _, err := ParseDependency(dep); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -69,6 +123,20 @@ func (c *Config) validateRequiredFields() error {
 	return nil
 }
 
+func (c *Config) compileConditions() error {
+	if c.Conditions.When == "" {
+		return nil
+	}
+
+	expr, err := condexpr.Parse(c.Conditions.When)
+	if err != nil {
+		return fmt.Errorf("invalid when expression: %w", err)
+	}
+
+	c.Conditions.when = expr
+	return nil
+}
+
 func (c *Config) validatePaths() error {
 	pathFields := c.GetPathFields()
 
@@ -84,5 +152,11 @@ func (c *Config) validatePaths() error {
 		}
 	}
 
+	for _, path := range c.VarsFiles {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("invalid vars file: %w", err)
+		}
+	}
+
 	return nil
 }
@@ -2,19 +2,23 @@ package module
 
 import (
 	"fmt"
-	"os"
-	"os/exec"
-	"runtime"
+	"path/filepath"
 	"strings"
 
 	"github.com/mermonia/peridot/internal/logger"
+	"github.com/mermonia/peridot/internal/paths"
 	"github.com/mermonia/peridot/internal/state"
+	"github.com/mermonia/peridot/internal/sysinfo"
+	"github.com/mermonia/peridot/internal/vars"
 )
 
 type Module struct {
 	Name   string
 	Config *Config
 	State  *state.ModuleState
+	// Variables are the effective template variables of the module. They
+	// default to the ones in its config, until ResolveVariables is called.
+	Variables map[string]any
 }
 
 func Load(dotfilesDir, moduleName string, moduleState *state.ModuleState) (*Module, error) {
@@ -24,12 +28,44 @@ func Load(dotfilesDir, moduleName string, moduleState *state.ModuleState) (*Modu
 	}
 
 	return &Module{
-		Name:   moduleName,
-		Config: c,
-		State:  moduleState,
+		Name:      moduleName,
+		Config:    c,
+		State:     moduleState,
+		Variables: c.TemplateVariables,
 	}, nil
 }
 
+// ResolveVariables merges the module's variables with the global and host
+// layers (see vars.Resolve), and makes the result the module's effective
+// variables.
+func (m *Module) ResolveVariables(dotfilesDir, hostname string, overrides map[string]any) (*vars.Set, error) {
+	moduleDir := paths.ModuleDir(dotfilesDir, m.Name)
+	layers := make([]vars.Layer, 0, len(m.Config.VarsFiles)+1)
+
+	for _, path := range m.Config.VarsFiles {
+		variables, err := vars.LoadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve variables of module %s: %w", m.Name, err)
+		}
+
+		source, err := filepath.Rel(moduleDir, path)
+		if err != nil {
+			source = path
+		}
+		layers = append(layers, vars.Layer{Source: source, Variables: variables})
+	}
+
+	layers = append(layers, vars.Layer{Source: vars.ModuleSource, Variables: m.Config.TemplateVariables})
+
+	set, err := vars.Resolve(dotfilesDir, hostname, layers, overrides)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve variables of module %s: %w", m.Name, err)
+	}
+
+	m.Variables = set.Values
+	return set, nil
+}
+
 func LoadAll(dotfilesDir string, appState *state.State) ([]*Module, error) {
 	modules := make([]*Module, 0, len(appState.Modules))
 
@@ -45,49 +81,54 @@ func LoadAll(dotfilesDir string, appState *state.State) ([]*Module, error) {
 	return modules, nil
 }
 
-func (m *Module) ShouldDeploy(appState *state.State) bool {
+// CheckDeployable returns an error explaining why the module cannot be
+// deployed, or nil if all of its dependencies and conditions are fulfilled.
+func (m *Module) CheckDeployable(appState *state.State) error {
 	if err := m.CheckBinaryDependencies(); err != nil {
 		logger.Warn("Binary dependencies missing", "error", err.Error())
-		return false
+		return err
 	}
 
 	if err := m.CheckModuleDependencies(appState); err != nil {
 		logger.Warn("Module dependencies missing", "error", err.Error())
-		return false
+		return err
 	}
 
 	if err := m.CheckConditions(); err != nil {
 		logger.Warn("Module condition not fullfilled", "error", err.Error())
-		return false
+		return err
 	}
 
-	return true
+	return nil
 }
 
 func (m *Module) CheckBinaryDependencies() error {
-	missing := []string{}
-
-	for _, dep := range m.Config.Dependencies {
-		if !binaryExists(dep) {
-			missing = append(missing, dep)
-		}
-	}
-
-	if len(missing) > 0 {
-		return fmt.Errorf("module %s has missing dependencies: [%s]", m.Name,
-			strings.Join(missing, ", "))
+	if unmet := m.Config.UnmetDependencies(); len(unmet) > 0 {
+		return fmt.Errorf("module %s has unmet dependencies: [%s]", m.Name,
+			strings.Join(unmet, "; "))
 	}
 
 	return nil
 }
 
-func binaryExists(bin string) bool {
-	if bin == "" {
-		return true
+// UnmetDependencies checks every binary dependency, returning a description
+// of each one that is missing or does not satisfy its version constraint.
+func (c *Config) UnmetDependencies() []string {
+	unmet := []string{}
+
+	for _, s := range c.Dependencies {
+		dep, err := ParseDependency(s)
+		if err != nil {
+			unmet = append(unmet, err.Error())
+			continue
+		}
+
+		if err := dep.Check(c.VersionProbes[dep.Binary]); err != nil {
+			unmet = append(unmet, err.Error())
+		}
 	}
 
-	_, err := exec.LookPath(bin)
-	return err == nil
+	return unmet
 }
 
 func (m *Module) CheckModuleDependencies(appState *state.State) error {
@@ -108,15 +149,8 @@ func (m *Module) CheckModuleDependencies(appState *state.State) error {
 }
 
 func (m *Module) CheckConditions() error {
-	requiredOs := strings.ToLower(m.Config.Conditions.OperatingSystem)
-	if requiredOs != "" && requiredOs != runtime.GOOS {
-		return fmt.Errorf("module %s requires os to be %s", m.Name, requiredOs)
-	}
-
-	for _, envvar := range m.Config.Conditions.EnvRequired {
-		if _, exists := os.LookupEnv(envvar); envvar != "" && !exists {
-			return fmt.Errorf("module %s requires environment variable %s to be set", m.Name, envvar)
-		}
+	if err := m.Config.Conditions.Check(sysinfo.Current(), m.Variables); err != nil {
+		return fmt.Errorf("module %s %w", m.Name, err)
 	}
 
 	return nil
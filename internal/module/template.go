@@ -0,0 +1,111 @@
+package module
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// Every text file is a template. This is the default.
+	TemplateModeAll = "all"
+	// Only files with the TemplateSuffix are templates, and they are
+	// deployed without it.
+	TemplateModeSuffix = "suffix"
+	// No file is a template, unless explicitly included.
+	TemplateModeNone = "none"
+
+	TemplateSuffix = ".tmpl"
+)
+
+type TemplateConfig struct {
+	Mode       string   `toml:"mode"`
+	Include    []string `toml:"include"`
+	Exclude    []string `toml:"exclude"`
+	LeftDelim  string   `toml:"left_delim"`
+	RightDelim string   `toml:"right_delim"`
+	// Strict is a pointer so that an unset value can default to true
+	Strict *bool `toml:"strict"`
+}
+
+// IsStrict reports whether references to undefined variables should make
+// rendering fail, which is the default.
+func (t *TemplateConfig) IsStrict() bool {
+	return t.Strict == nil || *t.Strict
+}
+
+func (t *TemplateConfig) validate() error {
+	switch t.Mode {
+	case "", TemplateModeAll, TemplateModeSuffix, TemplateModeNone:
+	default:
+		return fmt.Errorf("invalid template mode %q, expected one of %s, %s or %s", t.Mode,
+			TemplateModeAll, TemplateModeSuffix, TemplateModeNone)
+	}
+
+	if (t.LeftDelim == "") != (t.RightDelim == "") {
+		return fmt.Errorf("left_delim and right_delim must be set together")
+	}
+
+	for _, pattern := range append(append([]string{}, t.Include...), t.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid template pattern %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// IsTemplate reports whether the file at rel, relative to the module dir and
+// without any alternate suffix, should be rendered as a template. Exclude
+// patterns take precedence over include patterns, which in turn take
+// precedence over the mode.
+func (t *TemplateConfig) IsTemplate(rel string) bool {
+	if matchesAny(t.Exclude, rel) {
+		return false
+	}
+
+	if matchesAny(t.Include, rel) {
+		return true
+	}
+
+	switch t.Mode {
+	case TemplateModeSuffix:
+		return strings.HasSuffix(rel, TemplateSuffix)
+	case TemplateModeNone:
+		return false
+	default:
+		return true
+	}
+}
+
+// TemplatedNames reports whether file and dir names containing template
+// actions should be rendered, which is the case unless templating is off.
+func (t *TemplateConfig) TemplatedNames() bool {
+	return t.Mode != TemplateModeNone
+}
+
+// TargetPath returns the path a file is deployed as, rel being the path of
+// the file relative to the module dir. In suffix mode, templates are deployed
+// without the TemplateSuffix, while excluded files keep it.
+func (t *TemplateConfig) TargetPath(path, rel string) string {
+	if t.Mode == TemplateModeSuffix && t.IsTemplate(rel) {
+		return strings.TrimSuffix(path, TemplateSuffix)
+	}
+	return path
+}
+
+// matchesAny checks the patterns against both the whole relative path and
+// its base name, so that "*.conf" matches files in any directory.
+func matchesAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, rel); matched {
+			return true
+		}
+
+		if matched, _ := filepath.Match(pattern, filepath.Base(rel)); matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,19 @@
+package module
+
+import "testing"
+
+func TestTargetPath(t *testing.T) {
+	cfg := &TemplateConfig{Mode: TemplateModeSuffix, Exclude: []string{"raw.conf.tmpl"}}
+
+	cases := map[string]string{
+		"tmux.conf.tmpl": "/m/tmux.conf",
+		"raw.conf.tmpl":  "/m/raw.conf.tmpl",
+		"plain.conf":     "/m/plain.conf",
+	}
+
+	for rel, expected := range cases {
+		if path := cfg.TargetPath("/m/"+rel, rel); path != expected {
+			t.Fatalf("Expected %s to be deployed as %s, got %s", rel, expected, path)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package paths
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ExpandEnv replaces $VAR, ${VAR} and ${VAR:-default} in path with the values
+// of the environment variables. The default is used when the variable is
+// unset or empty, and may reference other variables itself. Unlike
+// os.ExpandEnv, referencing an unset variable without a default is an error,
+// as silently producing a different path could be destructive. "$$" yields
+// a literal "$".
+func ExpandEnv(path string) (string, error) {
+	var b strings.Builder
+
+	for i := 0; i < len(path); i++ {
+		if path[i] != '$' || i == len(path)-1 {
+			b.WriteByte(path[i])
+			continue
+		}
+
+		rest := path[i+1:]
+		switch {
+		case rest[0] == '$':
+			b.WriteByte('$')
+			i++
+
+		case rest[0] == '{':
+			end := matchingBrace(rest)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in %q", path)
+			}
+
+			value, err := expandBraced(rest[1:end])
+			if err != nil {
+				return "", err
+			}
+
+			b.WriteString(value)
+			i += end + 1
+
+		default:
+			n := nameLength(rest)
+			if n == 0 {
+				b.WriteByte('$')
+				continue
+			}
+
+			value, ok := os.LookupEnv(rest[:n])
+			if !ok {
+				return "", fmt.Errorf("the environment variable %s is not set", rest[:n])
+			}
+
+			b.WriteString(value)
+			i += n
+		}
+	}
+
+	return b.String(), nil
+}
+
+// expandBraced expands the contents of ${...}, i.e. "VAR" or "VAR:-default".
+func expandBraced(expr string) (string, error) {
+	name, def, hasDefault := strings.Cut(expr, ":-")
+	if nameLength(name) != len(name) || name == "" {
+		return "", fmt.Errorf("invalid variable reference ${%s}", expr)
+	}
+
+	value, ok := os.LookupEnv(name)
+	if hasDefault && value == "" {
+		return ExpandEnv(def)
+	}
+
+	if !ok {
+		return "", fmt.Errorf("the environment variable %s is not set and has no default", name)
+	}
+
+	return value, nil
+}
+
+// matchingBrace returns the index of the brace closing the one s starts
+// with, taking nested ${...} in defaults into account, or -1 if there is none.
+func matchingBrace(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// nameLength returns the length of the environment variable name s starts
+// with, which is 0 if it does not start with one.
+func nameLength(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		isLetter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !isLetter && (i == 0 || c < '0' || c > '9') {
+			return i
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,41 @@
+package paths
+
+import "testing"
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("PERIDOT_TEST_HOME", "/home/me")
+	t.Setenv("PERIDOT_TEST_EMPTY", "")
+
+	tests := []struct {
+		path     string
+		expected string
+		fails    bool
+	}{
+		{path: "$PERIDOT_TEST_HOME/.config", expected: "/home/me/.config"},
+		{path: "${PERIDOT_TEST_HOME}.d", expected: "/home/me.d"},
+		{path: "${PERIDOT_TEST_UNSET:-~/.config}/nvim", expected: "~/.config/nvim"},
+		{path: "${PERIDOT_TEST_EMPTY:-/tmp}", expected: "/tmp"},
+		{path: "${PERIDOT_TEST_UNSET:-${PERIDOT_TEST_HOME}/x}", expected: "/home/me/x"},
+		{path: "$PERIDOT_TEST_EMPTY/a", expected: "/a"},
+		{path: "a$$b$", expected: "a$b$"},
+		{path: "no/vars", expected: "no/vars"},
+		{path: "$PERIDOT_TEST_UNSET/a", fails: true},
+		{path: "${PERIDOT_TEST_UNSET}", fails: true},
+		{path: "${PERIDOT_TEST_HOME", fails: true},
+		{path: "${1BAD}", fails: true},
+	}
+
+	for _, test := range tests {
+		expanded, err := ExpandEnv(test.path)
+		if test.fails {
+			if err == nil {
+				t.Fatalf("Expected %q to fail to expand, got %q", test.path, expanded)
+			}
+			continue
+		}
+
+		if err != nil || expanded != test.expected {
+			t.Fatalf("Expected %q to expand to %q, got %q (%v)", test.path, test.expected, expanded, err)
+		}
+	}
+}
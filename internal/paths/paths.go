@@ -8,12 +8,16 @@ import (
 )
 
 const (
-	DotfilesDirEnvName   = "PERIDOT_DOTFILES_DIR"
-	PeridotDirName       = ".peridot"
-	StateFileName        = "state.json"
-	ModuleConfigFileName = "module.toml"
-	LogFileName          = "peridot.log"
-	DotreplacePrefix     = "dot-"
+	DotfilesDirEnvName    = "PERIDOT_DOTFILES_DIR"
+	PeridotDirName        = ".peridot"
+	StateFileName         = "state.json"
+	ModuleConfigFileName  = "module.toml"
+	LogFileName           = "peridot.log"
+	DotreplacePrefix      = "dot-"
+	GlobalConfigFileName  = "peridot.toml"
+	HostsDirName          = "hosts"
+	SharedPartialsDirName = "_templates"
+	PartialsDirName       = "_partials"
 )
 
 func ResolvePath(path string, base string) (string, error) {
@@ -118,6 +122,22 @@ func ModuleDir(dotfilesDir, moduleName string) string {
 	return filepath.Join(dotfilesDir, moduleName)
 }
 
+func GlobalConfigFilePath(dotfilesDir string) string {
+	return filepath.Join(dotfilesDir, GlobalConfigFileName)
+}
+
+func HostConfigFilePath(dotfilesDir, hostname string) string {
+	return filepath.Join(dotfilesDir, HostsDirName, hostname+".toml")
+}
+
+func SharedPartialsDir(dotfilesDir string) string {
+	return filepath.Join(dotfilesDir, SharedPartialsDirName)
+}
+
+func PartialsDir(dotfilesDir, moduleName string) string {
+	return filepath.Join(ModuleDir(dotfilesDir, moduleName), PartialsDirName)
+}
+
 func LogFilePath(dotfilesDir string) string {
 	return filepath.Join(PeridotDir(dotfilesDir), LogFileName)
 }
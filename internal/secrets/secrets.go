@@ -0,0 +1,276 @@
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mermonia/peridot/internal/config"
+	"github.com/mermonia/peridot/internal/paths"
+)
+
+// Types of secret providers, as set in the type field of their config.
+const (
+	// CommandProvider runs a command and reads the secret from its output.
+	// Every "{name}" in the command is replaced by the name of the secret,
+	// which is appended as the last argument if there is none.
+	CommandProvider = "command"
+	// EnvProvider reads the secret from the environment variable made of its
+	// prefix and the name of the secret, upper-cased and with every character
+	// other than letters, digits and underscores replaced by an underscore.
+	EnvProvider = "env"
+	// FileProvider reads the secret from a TOML file that should not be
+	// tracked. Dots in the name of the secret refer to nested tables.
+	FileProvider = "file"
+
+	NamePlaceholder = "{name}"
+)
+
+// Provider looks up the values of secrets by name.
+type Provider interface {
+	Lookup(name string) (string, error)
+}
+
+// Store resolves secrets through the configured providers. Every value is
+// only looked up once, as providers might be slow or prompt for passwords.
+// Values must never be logged or included in errors.
+type Store struct {
+	providers   map[string]Provider
+	defaultName string
+	stub        bool
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+func New(dotfilesDir string, cfg config.SecretsConfig) (*Store, error) {
+	s := &Store{
+		providers:   make(map[string]Provider, len(cfg.Providers)),
+		defaultName: cfg.Default,
+		cache:       map[string]string{},
+	}
+
+	for name, providerCfg := range cfg.Providers {
+		provider, err := newProvider(dotfilesDir, providerCfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret provider %s: %w", name, err)
+		}
+		s.providers[name] = provider
+	}
+
+	if s.defaultName == "" && len(s.providers) == 1 {
+		s.defaultName = slices.Collect(maps.Keys(s.providers))[0]
+	}
+
+	if s.defaultName != "" && s.providers[s.defaultName] == nil {
+		return nil, fmt.Errorf("the default secret provider %s is not configured", s.defaultName)
+	}
+
+	return s, nil
+}
+
+var (
+	loaded   = map[string]*Store{}
+	loadedMu sync.Mutex
+)
+
+// Load returns the store configured in the peridot.toml of the dotfiles dir.
+// It is only created once per run, so that secrets are cached across modules.
+func Load(dotfilesDir string) (*Store, error) {
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+
+	if s, ok := loaded[dotfilesDir]; ok {
+		return s, nil
+	}
+
+	cfg, err := config.Load(dotfilesDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not load global config: %w", err)
+	}
+
+	s, err := New(dotfilesDir, cfg.Secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded[dotfilesDir] = s
+	return s, nil
+}
+
+// Stub returns a store with the same providers that never looks up any
+// secret, resolving every one of them to an empty string instead. It allows
+// checking templates without accessing the secrets they use.
+func (s *Store) Stub() *Store {
+	return &Store{
+		providers:   s.providers,
+		defaultName: s.defaultName,
+		stub:        true,
+		cache:       map[string]string{},
+	}
+}
+
+// Get returns the secret with the given name from the given provider, or
+// from the default one if providerName is empty.
+func (s *Store) Get(providerName, name string) (string, error) {
+	if providerName == "" {
+		if s.defaultName == "" {
+			return "", fmt.Errorf("no default secret provider is configured in %s", paths.GlobalConfigFileName)
+		}
+		providerName = s.defaultName
+	}
+
+	provider := s.providers[providerName]
+	if provider == nil {
+		return "", fmt.Errorf("unknown secret provider %s", providerName)
+	}
+
+	if s.stub {
+		return "", nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := providerName + "\x00" + name
+	if value, ok := s.cache[key]; ok {
+		return value, nil
+	}
+
+	value, err := provider.Lookup(name)
+	if err != nil {
+		return "", fmt.Errorf("could not get secret %s from provider %s: %w", name, providerName, err)
+	}
+
+	s.cache[key] = value
+	return value, nil
+}
+
+func newProvider(dotfilesDir string, cfg config.SecretProvider) (Provider, error) {
+	switch cfg.Type {
+	case CommandProvider:
+		if strings.TrimSpace(cfg.Command) == "" {
+			return nil, fmt.Errorf("a command provider requires a command")
+		}
+		return &commandProvider{command: cfg.Command}, nil
+	case EnvProvider:
+		return &envProvider{prefix: cfg.Prefix}, nil
+	case FileProvider:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("a file provider requires a path")
+		}
+		path, err := paths.ResolvePath(cfg.Path, dotfilesDir)
+		if err != nil {
+			return nil, err
+		}
+		return &fileProvider{path: path}, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q, must be one of %s, %s or %s",
+			cfg.Type, CommandProvider, EnvProvider, FileProvider)
+	}
+}
+
+type commandProvider struct {
+	command string
+}
+
+// Lookup runs the command without a shell. Its output is the secret, without
+// the trailing newline.
+func (p *commandProvider) Lookup(name string) (string, error) {
+	parts := strings.Fields(p.command)
+	if !strings.Contains(p.command, NamePlaceholder) {
+		parts = append(parts, name)
+	}
+	for i, part := range parts {
+		parts[i] = strings.ReplaceAll(part, NamePlaceholder, name)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(parts[0], parts[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
+
+type envProvider struct {
+	prefix string
+}
+
+func (p *envProvider) Lookup(name string) (string, error) {
+	envName := p.prefix + EnvName(name)
+
+	value, ok := os.LookupEnv(envName)
+	if !ok {
+		return "", fmt.Errorf("the environment variable %s is not set", envName)
+	}
+
+	return value, nil
+}
+
+// EnvName returns the environment variable name the env provider reads a
+// secret from, before adding its prefix (e.g. "github/token" is GITHUB_TOKEN).
+func EnvName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+type fileProvider struct {
+	path string
+
+	once    sync.Once
+	secrets map[string]any
+	err     error
+}
+
+func (p *fileProvider) Lookup(name string) (string, error) {
+	p.once.Do(func() {
+		p.secrets = map[string]any{}
+		if _, err := toml.DecodeFile(p.path, &p.secrets); err != nil {
+			p.err = fmt.Errorf("could not decode secrets file %s: %w", p.path, err)
+		}
+	})
+	if p.err != nil {
+		return "", p.err
+	}
+
+	var current any = p.secrets
+	for key := range strings.SplitSeq(name, ".") {
+		table, ok := current.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("secret not found in %s", p.path)
+		}
+		if current, ok = table[key]; !ok {
+			return "", fmt.Errorf("secret not found in %s", p.path)
+		}
+	}
+
+	value, ok := current.(string)
+	if !ok {
+		return "", fmt.Errorf("the secret in %s is not a string", p.path)
+	}
+
+	return value, nil
+}
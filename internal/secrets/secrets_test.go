@@ -0,0 +1,71 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mermonia/peridot/internal/config"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "calls")
+	script := filepath.Join(dir, "provider.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho >>"+counter+"\nprintf \"from-$1\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secrets.toml"), []byte("[gh]\ntoken = \"from-file\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECRET_NPM_TOKEN", "from-env")
+
+	store, err := New(dir, config.SecretsConfig{
+		Default: "cmd",
+		Providers: map[string]config.SecretProvider{
+			"cmd":   {Type: CommandProvider, Command: script + " {name}"},
+			"env":   {Type: EnvProvider, Prefix: "SECRET_"},
+			"local": {Type: FileProvider, Path: "secrets.toml"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Could not create store: %v", err)
+	}
+
+	tests := []struct {
+		provider string
+		name     string
+		expected string
+	}{
+		{"", "cmd", "from-cmd"},
+		{"cmd", "cmd", "from-cmd"},
+		{"env", "npm/token", "from-env"},
+		{"local", "gh.token", "from-file"},
+	}
+
+	for _, test := range tests {
+		value, err := store.Get(test.provider, test.name)
+		if err != nil {
+			t.Fatalf("Could not get secret %s from %q: %v", test.name, test.provider, err)
+		}
+		if value != test.expected {
+			t.Fatalf("Expected secret %s from %q to be %q, got %q", test.name, test.provider, test.expected, value)
+		}
+	}
+
+	if calls, _ := os.ReadFile(counter); len(calls) != 1 {
+		t.Fatalf("Expected the command to run once, got %q", calls)
+	}
+
+	if _, err := store.Get("env", "missing"); err == nil {
+		t.Fatalf("Expected an error for an unset environment variable")
+	}
+
+	if _, err := store.Get("nope", "x"); err == nil {
+		t.Fatalf("Expected an error for an unknown provider")
+	}
+
+	if value, err := store.Stub().Get("local", "missing"); err != nil || value != "" {
+		t.Fatalf("Expected stubbed secrets to be empty, got %q, %v", value, err)
+	}
+}
@@ -0,0 +1,104 @@
+package state
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/mermonia/peridot/internal/hash"
+)
+
+// Drift describes how the deployed side of an entry (the symlink and the
+// intermediate file it points to) differs from what peridot left there. It is
+// not persisted, but computed by Refresh.
+type Drift int
+
+const (
+	NoDrift Drift = iota
+	// The deployed file was edited in place, through the symlink
+	TargetModified
+	// There is nothing at the symlink path anymore
+	LinkMissing
+	// The symlink points somewhere other than the intermediate file
+	ForeignLink
+	// The symlink was replaced by a regular file or dir
+	ReplacedByFile
+	// The intermediate file the symlink points to was removed
+	IntermediateMissing
+)
+
+func (d Drift) String() string {
+	switch d {
+	case NoDrift:
+		return "none"
+	case TargetModified:
+		return "target modified"
+	case LinkMissing:
+		return "missing link"
+	case ForeignLink:
+		return "foreign link"
+	case ReplacedByFile:
+		return "replaced by file"
+	case IntermediateMissing:
+		return "intermediate missing"
+	default:
+		return "unknown"
+	}
+}
+
+// ModifiedTargets returns the sorted symlink paths of the deployed files of
+// the module that were edited since they were deployed.
+func (m *ModuleState) ModifiedTargets() []string {
+	modified := []string{}
+	for _, entry := range m.Files {
+		if entry.Drift == TargetModified {
+			modified = append(modified, entry.SymlinkPath)
+		}
+	}
+
+	slices.Sort(modified)
+	return modified
+}
+
+// checkDrift inspects the symlink and the intermediate file of the entry. For
+// foreign links, it also returns where the link points to.
+func (e *Entry) checkDrift() (Drift, string) {
+	info, err := os.Lstat(e.SymlinkPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return LinkMissing, ""
+	}
+	if err != nil {
+		// The link cannot be inspected, so there is nothing to report
+		return NoDrift, ""
+	}
+
+	if info.Mode()&os.ModeSymlink == 0 {
+		return ReplacedByFile, ""
+	}
+
+	dest, err := os.Readlink(e.SymlinkPath)
+	if err != nil {
+		return NoDrift, ""
+	}
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(filepath.Dir(e.SymlinkPath), dest)
+	}
+	if filepath.Clean(dest) != filepath.Clean(e.IntermediatePath) {
+		return ForeignLink, dest
+	}
+
+	if _, err := os.Stat(e.IntermediatePath); errors.Is(err, fs.ErrNotExist) {
+		return IntermediateMissing, ""
+	}
+
+	if e.IntermediateHash != "" {
+		updatedHash, err := hash.HashFile(e.IntermediatePath)
+		if err == nil && updatedHash != e.IntermediateHash {
+			return TargetModified, ""
+		}
+	}
+
+	return NoDrift, ""
+}
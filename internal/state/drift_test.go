@@ -0,0 +1,63 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mermonia/peridot/internal/hash"
+)
+
+func TestCheckDrift(t *testing.T) {
+	dir := t.TempDir()
+
+	intermediate := filepath.Join(dir, "intermediate")
+	if err := os.WriteFile(intermediate, []byte("deployed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	deployedHash, err := hash.HashFile(intermediate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		setup    func(link string) error
+		expected Drift
+	}{
+		{"synced", func(link string) error { return os.Symlink(intermediate, link) }, NoDrift},
+		{"missing", func(link string) error { return nil }, LinkMissing},
+		{"foreign", func(link string) error { return os.Symlink(filepath.Join(dir, "elsewhere"), link) }, ForeignLink},
+		{"file", func(link string) error { return os.WriteFile(link, []byte("deployed\n"), 0644) }, ReplacedByFile},
+	}
+
+	for _, test := range tests {
+		link := filepath.Join(dir, test.name)
+		if err := test.setup(link); err != nil {
+			t.Fatal(err)
+		}
+
+		entry := &Entry{SymlinkPath: link, IntermediatePath: intermediate, IntermediateHash: deployedHash}
+		if drift, _ := entry.checkDrift(); drift != test.expected {
+			t.Fatalf("Expected drift of %s to be %q, got %q", test.name, test.expected, drift)
+		}
+	}
+
+	link := filepath.Join(dir, "synced")
+
+	if err := os.WriteFile(intermediate, []byte("edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entry := &Entry{SymlinkPath: link, IntermediatePath: intermediate, IntermediateHash: deployedHash}
+	if drift, _ := entry.checkDrift(); drift != TargetModified {
+		t.Fatalf("Expected an edited intermediate to be %q, got %q", TargetModified, drift)
+	}
+
+	if err := os.Remove(intermediate); err != nil {
+		t.Fatal(err)
+	}
+	if drift, _ := entry.checkDrift(); drift != IntermediateMissing {
+		t.Fatalf("Expected a removed intermediate to be %q, got %q", IntermediateMissing, drift)
+	}
+}
@@ -0,0 +1,201 @@
+package state
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mermonia/peridot/internal/paths"
+)
+
+// ReportVersion is the version of the schema of Report and of the porcelain
+// format. It is increased on every change that could break their consumers;
+// adding new fields or new status values does not count as such.
+const ReportVersion = 1
+
+// PorcelainHeader is the first line of the porcelain format.
+var PorcelainHeader = fmt.Sprintf("# peridot status v%d", ReportVersion)
+
+// Report is a machine-readable view of the state, meant for scripts and
+// other tools that should not parse the status tree.
+type Report struct {
+	Version int            `json:"version"`
+	Modules []ModuleReport `json:"modules"`
+}
+
+type ModuleReport struct {
+	Name string `json:"name"`
+	// Status is "unmanaged" for module dirs that are not in the state
+	Status string `json:"status"`
+	// DeployedAt is empty if the module was never deployed
+	DeployedAt string `json:"deployedAt,omitempty"`
+	// Note explains why the module cannot be deployed, if that is the case
+	Note  string       `json:"note,omitempty"`
+	Files []FileReport `json:"files"`
+}
+
+type FileReport struct {
+	// Path is relative to the module dir, while the rest are absolute
+	Path         string `json:"path"`
+	Source       string `json:"source"`
+	Intermediate string `json:"intermediate"`
+	Target       string `json:"target"`
+	// Status is "unsynced" if the configuration of the file changed, even
+	// if its source did not, and "new" or "ignored" for files that are not
+	// in the state, which have no intermediate nor target
+	Status           string `json:"status"`
+	Template         bool   `json:"template"`
+	SourceHash       string `json:"sourceHash"`
+	IntermediateHash string `json:"intermediateHash,omitempty"`
+	DeployedAt       string `json:"deployedAt,omitempty"`
+	Drift            string `json:"drift"`
+	// DriftDetail is where the link points to, for foreign links
+	DriftDetail string `json:"driftDetail,omitempty"`
+	// ConfigChanges lists what changed in the configuration the file was
+	// deployed with: "variables", "root" or "target"
+	ConfigChanges []string `json:"configChanges,omitempty"`
+}
+
+// Key returns the name of the status in reports.
+func (s DeployStatus) Key() string {
+	switch s {
+	case NotDeployed:
+		return "not_deployed"
+	case Unsynced:
+		return "unsynced"
+	case Synced:
+		return "synced"
+	default:
+		return "unknown"
+	}
+}
+
+// Key returns the name of the drift in reports.
+func (d Drift) Key() string {
+	return strings.ReplaceAll(d.String(), " ", "_")
+}
+
+// NewReport builds the report of the modules in the state, sorted by name, as
+// well as their files. The notes are attached to the modules like in the tree.
+func NewReport(state *State, dotfilesDir string, opts *TreeOptions) (*Report, error) {
+	report := &Report{Version: ReportVersion, Modules: []ModuleReport{}}
+
+	for name, module := range state.Modules {
+		moduleReport := ModuleReport{
+			Name:       name,
+			Status:     module.EffectiveStatus().Key(),
+			DeployedAt: formatTime(module.DeployedAt),
+			Files:      []FileReport{},
+		}
+		if opts != nil {
+			moduleReport.Note = opts.Notes[name]
+		}
+
+		for source, entry := range module.Files {
+			path, err := filepath.Rel(paths.ModuleDir(dotfilesDir, name), source)
+			if err != nil {
+				return nil, err
+			}
+
+			moduleReport.Files = append(moduleReport.Files, FileReport{
+				Path:             path,
+				Source:           source,
+				Intermediate:     entry.IntermediatePath,
+				Target:           entry.SymlinkPath,
+				Status:           entry.EffectiveStatus().Key(),
+				Template:         entry.Template,
+				SourceHash:       entry.SourceHash,
+				IntermediateHash: entry.IntermediateHash,
+				DeployedAt:       formatTime(entry.DeployedAt),
+				Drift:            entry.Drift.Key(),
+				DriftDetail:      entry.DriftDetail,
+				ConfigChanges:    entry.ConfigChanges,
+			})
+		}
+
+		if opts != nil {
+			for _, source := range opts.NewFiles[name] {
+				moduleReport.Files = append(moduleReport.Files, untrackedFileReport(dotfilesDir, name, source, "new"))
+			}
+			for _, source := range opts.IgnoredFiles[name] {
+				moduleReport.Files = append(moduleReport.Files, untrackedFileReport(dotfilesDir, name, source, "ignored"))
+			}
+		}
+
+		slices.SortFunc(moduleReport.Files, func(a, b FileReport) int {
+			return strings.Compare(a.Path, b.Path)
+		})
+		report.Modules = append(report.Modules, moduleReport)
+	}
+
+	if opts != nil {
+		for _, name := range opts.Unmanaged {
+			report.Modules = append(report.Modules, ModuleReport{Name: name, Status: "unmanaged", Files: []FileReport{}})
+		}
+	}
+
+	slices.SortFunc(report.Modules, func(a, b ModuleReport) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return report, nil
+}
+
+// WritePorcelain writes the report as tab-separated lines, after the
+// PorcelainHeader. Modules are written as
+//
+//	module <name> <status> <deployedAt or ->
+//
+// followed by a line per file:
+//
+//	file <module> <status> <drift> <path> <source> <intermediate or -> <target or ->
+func (r *Report) WritePorcelain(out io.Writer) error {
+	if _, err := fmt.Fprintln(out, PorcelainHeader); err != nil {
+		return err
+	}
+
+	for _, module := range r.Modules {
+		fields := []string{"module", module.Name, module.Status, orDash(module.DeployedAt)}
+		if _, err := fmt.Fprintln(out, strings.Join(fields, "\t")); err != nil {
+			return err
+		}
+
+		for _, file := range module.Files {
+			fields := []string{"file", module.Name, file.Status, file.Drift,
+				file.Path, file.Source, orDash(file.Intermediate), orDash(file.Target)}
+			if _, err := fmt.Fprintln(out, strings.Join(fields, "\t")); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// untrackedFileReport reports a file that is not in the state, whose status is
+// either "new" or "ignored".
+func untrackedFileReport(dotfilesDir, moduleName, source, status string) FileReport {
+	path, err := filepath.Rel(paths.ModuleDir(dotfilesDir, moduleName), source)
+	if err != nil {
+		path = source
+	}
+
+	return FileReport{Path: path, Source: source, Status: status, Drift: NoDrift.Key()}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
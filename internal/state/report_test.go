@@ -0,0 +1,72 @@
+package state
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	dir := t.TempDir()
+	moduleDir := filepath.Join(dir, "shell")
+	termDir := filepath.Join(dir, "term")
+
+	st := &State{Modules: map[string]*ModuleState{
+		"shell": {
+			Status: Unsynced,
+			Files: map[string]*Entry{
+				filepath.Join(moduleDir, "b"): {Status: Unsynced, SymlinkPath: "/home/b", IntermediatePath: "/i/b"},
+				filepath.Join(moduleDir, "a"): {Status: Synced, SymlinkPath: "/home/a", IntermediatePath: "/i/a", Drift: LinkMissing},
+			},
+		},
+		"editor": {Status: NotDeployed, Files: map[string]*Entry{}},
+		// Files whose config changed are unsynced, and so are their modules
+		"term": {
+			Status: Synced,
+			Files: map[string]*Entry{
+				filepath.Join(termDir, "d"): {Status: Synced, SymlinkPath: "/home/d", IntermediatePath: "/i/d",
+					ConfigChanges: []string{"variables"}},
+			},
+		},
+	}}
+
+	opts := &TreeOptions{
+		Notes:     map[string]string{"editor": "ineligible"},
+		NewFiles:  map[string][]string{"shell": {filepath.Join(moduleDir, "c")}},
+		Unmanaged: []string{"music"},
+	}
+
+	report, err := NewReport(st, dir, opts)
+	if err != nil {
+		t.Fatalf("Could not build report: %v", err)
+	}
+
+	var out strings.Builder
+	if err := report.WritePorcelain(&out); err != nil {
+		t.Fatalf("Could not write porcelain: %v", err)
+	}
+
+	expected := strings.Join([]string{
+		PorcelainHeader,
+		"module\teditor\tnot_deployed\t-",
+		"module\tmusic\tunmanaged\t-",
+		"module\tshell\tunsynced\t-",
+		"file\tshell\tsynced\tmissing_link\ta\t" + filepath.Join(moduleDir, "a") + "\t/i/a\t/home/a",
+		"file\tshell\tunsynced\tnone\tb\t" + filepath.Join(moduleDir, "b") + "\t/i/b\t/home/b",
+		"file\tshell\tnew\tnone\tc\t" + filepath.Join(moduleDir, "c") + "\t-\t-",
+		"module\tterm\tunsynced\t-",
+		"file\tterm\tunsynced\tnone\td\t" + filepath.Join(termDir, "d") + "\t/i/d\t/home/d",
+	}, "\n") + "\n"
+
+	if out.String() != expected {
+		t.Fatalf("Expected porcelain output:\n%s\ngot:\n%s", expected, out.String())
+	}
+
+	if report.Modules[0].Note != "ineligible" {
+		t.Fatalf("Expected the note to be attached to the editor module, got %q", report.Modules[0].Note)
+	}
+
+	if changes := report.Modules[3].Files[0].ConfigChanges; len(changes) != 1 || changes[0] != "variables" {
+		t.Fatalf("Expected the config changes of d to be reported, got %v", changes)
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/mermonia/peridot/internal/alternate"
 	"github.com/mermonia/peridot/internal/hash"
 	"github.com/mermonia/peridot/internal/paths"
 	"github.com/mermonia/peridot/internal/tree"
@@ -30,6 +33,30 @@ type Entry struct {
 	SourceHash       string       `json:"hash"`
 	IntermediatePath string       `json:"intermediatePath"`
 	SymlinkPath      string       `json:"symlinkPath"`
+	Template         bool         `json:"template,omitempty"`
+	// Dependencies maps the files the rendered output depends on (such as
+	// partials) to their hashes at the time of deployment.
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+	// IntermediateHash is the hash of the intermediate file right after it
+	// was deployed, which tells whether the deployed file was edited since.
+	IntermediateHash string `json:"intermediateHash,omitempty"`
+	// DeployedAt is the last time the file was deployed
+	DeployedAt time.Time `json:"deployedAt"`
+	// VariablesHash (for templates), Root and Dotreplace record the
+	// configuration the file was deployed with, so that changes to it can
+	// be detected. RootOverridden is set if the root came from --root
+	// instead of the module config.
+	VariablesHash  string `json:"variablesHash,omitempty"`
+	Root           string `json:"root,omitempty"`
+	RootOverridden bool   `json:"rootOverridden,omitempty"`
+	Dotreplace     bool   `json:"dotreplace,omitempty"`
+	// ConfigChanges lists what changed in that configuration (variables,
+	// root or target). It is set by the status command.
+	ConfigChanges []string `json:"-"`
+	// Drift is set by Refresh, along with DriftDetail (where a foreign link
+	// points to).
+	Drift       Drift  `json:"-"`
+	DriftDetail string `json:"-"`
 }
 
 type DeployStatus int
@@ -40,6 +67,31 @@ const (
 	Synced
 )
 
+// EffectiveStatus is the status the entry is reported with. An entry whose
+// configuration changed (see ConfigChanges) is as good as unsynced, even if
+// its source is not.
+func (e *Entry) EffectiveStatus() DeployStatus {
+	if e.Status == Synced && len(e.ConfigChanges) > 0 {
+		return Unsynced
+	}
+	return e.Status
+}
+
+// EffectiveStatus is the status the module is reported with, which is
+// unsynced if the effective status of any of its files is.
+func (m *ModuleState) EffectiveStatus() DeployStatus {
+	if m.Status != Synced {
+		return m.Status
+	}
+
+	for _, entry := range m.Files {
+		if entry.EffectiveStatus() == Unsynced {
+			return Unsynced
+		}
+	}
+	return Synced
+}
+
 func LoadState(dotfilesDir string) (*State, error) {
 	state := &State{}
 	stateFile, err := os.ReadFile(paths.StateFilePath(dotfilesDir))
@@ -67,64 +119,226 @@ func SaveState(state *State, dotfilesDir string) error {
 	return nil
 }
 
-func GetStateFileTree(state *State, dotfilesDir string) (*tree.Node, error) {
+// TreeOptions customizes the file trees built from the state.
+type TreeOptions struct {
+	// Notes maps module names to a short remark (e.g. why the module is
+	// not eligible for deployment) that is appended to the module's node.
+	Notes map[string]string
+	// NewFiles maps module names to the files in their dirs that are not
+	// in the state, but would be deployed by the next deployment.
+	NewFiles map[string][]string
+	// IgnoredFiles maps module names to the files in their dirs that are
+	// skipped by deployments. They are only shown if set.
+	IgnoredFiles map[string][]string
+	// Unmanaged lists the dirs in the dotfiles dir that contain a module
+	// config, but are not in the state.
+	Unmanaged []string
+	// Only lists the filters (see Filters) that modules and files must
+	// match to be shown. Everything is shown if empty.
+	Only []string
+	// ModulesOnly leaves the files of the modules out of the tree
+	ModulesOnly bool
+	// Symbols defaults to DefaultStatusSymbols
+	Symbols *StatusSymbols
+}
+
+// Filters accepted by TreeOptions.Only. A module matches its own status, and
+// is shown as well if any of its files is.
+const (
+	FilterSynced      = "synced"
+	FilterUnsynced    = "unsynced"
+	FilterNotDeployed = "not_deployed"
+	FilterDrift       = "drift"
+	FilterNew         = "new"
+	FilterIgnored     = "ignored"
+	FilterUnmanaged   = "unmanaged"
+)
+
+var Filters = []string{FilterSynced, FilterUnsynced, FilterNotDeployed, FilterDrift,
+	FilterNew, FilterIgnored, FilterUnmanaged}
+
+// shows reports whether anything matching any of the filters is shown.
+func (o *TreeOptions) shows(filters ...string) bool {
+	if o == nil || len(o.Only) == 0 {
+		return true
+	}
+
+	for _, filter := range filters {
+		if slices.Contains(o.Only, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *TreeOptions) symbols() *StatusSymbols {
+	if o == nil || o.Symbols == nil {
+		return &DefaultStatusSymbols
+	}
+	return o.Symbols
+}
+
+func GetStateFileTree(state *State, dotfilesDir string, opts *TreeOptions) (*tree.Node, error) {
 	newTree := tree.NewTree(".")
 
 	// Systematically add nodes to the tree
 	for name, module := range state.Modules {
 		// Each module is a first-level node
-		moduleNode, err := GetModuleFileTree(name, module, dotfilesDir)
+		moduleNode, err := GetModuleFileTree(name, module, dotfilesDir, opts)
 		if err != nil {
 			return nil, fmt.Errorf("could not get moudule file tree: %w", err)
 		}
 
+		if !opts.shows(module.EffectiveStatus().Key()) && !moduleHasShownFiles(name, module, opts) {
+			continue
+		}
+
 		if err := newTree.Add(moduleNode); err != nil {
 			return nil, fmt.Errorf("could not add node to the tree: %w", err)
 		}
 	}
 
+	if opts != nil && opts.shows(FilterUnmanaged) {
+		for _, name := range opts.Unmanaged {
+			formattedStatus := opts.symbols().Unmanaged + " " + name + " - not managed (run 'peridot add " + name + "')"
+			node, err := newTree.AddValue(formattedStatus)
+			if err != nil {
+				return nil, fmt.Errorf("could not add node to the tree: %w", err)
+			}
+			node.Key = name
+		}
+	}
+
+	// Modules are sorted by name, while their files are already sorted
+	slices.SortStableFunc(newTree.Nodes, func(a, b *tree.Node) int {
+		return strings.Compare(a.SortKey(), b.SortKey())
+	})
+
 	return newTree, nil
 }
 
-func GetModuleFileTree(name string, module *ModuleState, dotfilesDir string) (*tree.Node, error) {
-	formattedStatus := getFormattedModuleStatus(name, module)
+func GetModuleFileTree(name string, module *ModuleState, dotfilesDir string, opts *TreeOptions) (*tree.Node, error) {
+	syms := opts.symbols()
+
+	formattedStatus := getFormattedModuleStatus(name, module, syms)
+	if opts != nil && module.Status != NotDeployed && len(opts.NewFiles[name]) > 0 {
+		formattedStatus += fmt.Sprintf(", %d new file(s)", len(opts.NewFiles[name]))
+	}
+	if opts != nil && opts.Notes[name] != "" {
+		formattedStatus += " (" + opts.Notes[name] + ")"
+	}
 	moduleNode := tree.NewTree(formattedStatus)
+	moduleNode.Key = name
+	moduleDir := paths.ModuleDir(dotfilesDir, name)
 
-	// Each dir below a module dir is a node.
-	// A file inside one of those dirs is a leafless node.
 	for path, entry := range module.Files {
-		path, err := filepath.Rel(paths.ModuleDir(dotfilesDir, name), path)
-		if err != nil {
+		if !opts.shows(entry.filters()...) {
+			continue
+		}
+
+		if err := addFileNode(moduleNode, moduleDir, path, func(fileName string) string {
+			return getFormattedFileStatus(fileName, entry, syms)
+		}); err != nil {
 			return nil, err
 		}
+	}
 
-		dirPath, fileName := filepath.Split(path)
-		dirList := paths.SplitPath(dirPath)
+	if opts != nil && opts.shows(FilterNew) {
+		for _, path := range opts.NewFiles[name] {
+			if err := addFileNode(moduleNode, moduleDir, path, func(fileName string) string {
+				return syms.New + " " + formatFileName(fileName) + " (new, not yet deployed)"
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
 
-		lastNode := moduleNode
-		for _, dir := range dirList {
-			// Check if the node is the root, or an immediate child
-			node := lastNode.GetNodeByValueBFS(dir, 2)
-			if node == nil {
-				lastNode, err = lastNode.AddValue(dir)
-				if err != nil {
-					return nil, err
-				}
-			} else {
-				lastNode = node
+	if opts != nil && opts.shows(FilterIgnored) {
+		for _, path := range opts.IgnoredFiles[name] {
+			if err := addFileNode(moduleNode, moduleDir, path, func(fileName string) string {
+				return syms.Ignored + " " + fileName + " (ignored)"
+			}); err != nil {
+				return nil, err
 			}
 		}
+	}
 
-		// Since a map does not allow duplicate keys, we don't have to
-		// check for that.
-		formattedFileStatus := getFormattedFileStatus(fileName, entry)
-		if _, err := lastNode.AddValue(formattedFileStatus); err != nil {
-			return nil, err
+	// Dirs go first, then files, each sorted by name
+	moduleNode.SortFunc(func(a, b *tree.Node) int {
+		if aIsDir, bIsDir := len(a.Nodes) > 0, len(b.Nodes) > 0; aIsDir != bIsDir {
+			if aIsDir {
+				return -1
+			}
+			return 1
 		}
+		return strings.Compare(a.SortKey(), b.SortKey())
+	})
+
+	if opts != nil && opts.ModulesOnly {
+		moduleNode.Truncate(0)
 	}
+
 	return moduleNode, nil
 }
 
+// moduleHasShownFiles reports whether any file of the module matches the
+// filters, which keeps the module in the tree even if it does not.
+func moduleHasShownFiles(name string, module *ModuleState, opts *TreeOptions) bool {
+	for _, entry := range module.Files {
+		if opts.shows(entry.filters()...) {
+			return true
+		}
+	}
+
+	return (len(opts.NewFiles[name]) > 0 && opts.shows(FilterNew)) ||
+		(len(opts.IgnoredFiles[name]) > 0 && opts.shows(FilterIgnored))
+}
+
+// filters returns the filters the entry matches.
+func (e *Entry) filters() []string {
+	filters := []string{e.EffectiveStatus().Key()}
+	if e.Drift != NoDrift {
+		filters = append(filters, FilterDrift)
+	}
+	return filters
+}
+
+// addFileNode adds the file at path, inside the module dir, to the module
+// node. Each dir below a module dir is a node, and a file inside one of those
+// dirs is a leafless node, whose value is given by format.
+func addFileNode(moduleNode *tree.Node, moduleDir, path string, format func(fileName string) string) error {
+	path, err := filepath.Rel(moduleDir, path)
+	if err != nil {
+		return err
+	}
+
+	dirPath, fileName := filepath.Split(path)
+	dirList := paths.SplitPath(dirPath)
+
+	lastNode := moduleNode
+	for _, dir := range dirList {
+		// Check if the node is the root, or an immediate child
+		node := lastNode.GetNodeByValueBFS(dir, 2)
+		if node == nil {
+			lastNode, err = lastNode.AddValue(dir)
+			if err != nil {
+				return err
+			}
+		} else {
+			lastNode = node
+		}
+	}
+
+	// Since the files come from a map or a walk of the module dir, there
+	// are no duplicates to check for.
+	node, err := lastNode.AddValue(format(fileName))
+	if err != nil {
+		return err
+	}
+	node.Key = fileName
+	return nil
+}
+
 func (s *State) Refresh(dotfilesDir string) error {
 	s.cleanModules(dotfilesDir)
 	return s.updateDeploymentStatus()
@@ -139,12 +353,13 @@ func (s *State) updateDeploymentStatus() error {
 					return fmt.Errorf("could not hash file %s: %w", path, err)
 				}
 
-				if updatedHash != file.SourceHash {
+				if updatedHash != file.SourceHash || file.dependenciesChanged() {
 					file.Status = Unsynced
 					module.Status = Unsynced
 				}
 
 				file.SourceHash = updatedHash
+				file.Drift, file.DriftDetail = file.checkDrift()
 			}
 		}
 	}
@@ -152,6 +367,19 @@ func (s *State) updateDeploymentStatus() error {
 	return nil
 }
 
+// dependenciesChanged reports whether any of the files the entry depends on
+// was modified or removed since it was deployed.
+func (e *Entry) dependenciesChanged() bool {
+	for path, depHash := range e.Dependencies {
+		updatedHash, err := hash.HashFile(path)
+		if err != nil || updatedHash != depHash {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (s *State) cleanModules(dotfilesDir string) {
 	for name, module := range s.Modules {
 		for path := range module.Files {
@@ -166,37 +394,96 @@ func (s *State) cleanModules(dotfilesDir string) {
 	}
 }
 
-func getFormattedModuleStatus(name string, module *ModuleState) string {
+func getFormattedModuleStatus(name string, module *ModuleState, syms *StatusSymbols) string {
 	formattedStatus := ""
 
-	switch module.Status {
+	drifted, configChanged := 0, 0
+	for _, entry := range module.Files {
+		if entry.Drift != NoDrift {
+			drifted++
+		}
+		if len(entry.ConfigChanges) > 0 {
+			configChanged++
+		}
+	}
+
+	switch module.EffectiveStatus() {
 	case NotDeployed:
-		formattedStatus = "○ " + name + " - not deployed"
+		formattedStatus = syms.NotDeployed + " " + name + " - not deployed"
 	case Unsynced:
-		formattedStatus = "✗ " + name + " - deployed, pending sync"
+		formattedStatus = syms.Unsynced + " " + name + " - deployed, pending sync"
 	case Synced:
-		formattedStatus = "✓ " + name + " - deployed and up to date"
+		formattedStatus = syms.Synced + " " + name + " - deployed and up to date"
 	default:
-		formattedStatus = "? " + name + " - status unknown"
+		formattedStatus = syms.Unknown + " " + name + " - status unknown"
+
+	}
 
+	if configChanged > 0 {
+		formattedStatus += fmt.Sprintf(", config changed for %d file(s)", configChanged)
+	}
+	if drifted > 0 {
+		formattedStatus += fmt.Sprintf(", %d deployed file(s) drifted", drifted)
 	}
 
 	return formattedStatus
 }
 
-func getFormattedFileStatus(name string, entry *Entry) string {
+func getFormattedFileStatus(name string, entry *Entry, syms *StatusSymbols) string {
 	formattedFileStatus := ""
+	name = formatFileName(name)
+
+	if entry.Template {
+		name += " [template]"
+	}
 
-	switch entry.Status {
+	switch entry.EffectiveStatus() {
 	case NotDeployed:
 		formattedFileStatus = name
 	case Unsynced:
-		formattedFileStatus = "✗ " + name + " <- " + entry.SymlinkPath
+		formattedFileStatus = syms.Unsynced + " " + name + " <- " + entry.SymlinkPath
 	case Synced:
-		formattedFileStatus = "✓ " + name + " <- " + entry.SymlinkPath
+		formattedFileStatus = syms.Synced + " " + name + " <- " + entry.SymlinkPath
 	default:
-		formattedFileStatus = "? " + name
+		formattedFileStatus = syms.Unknown + " " + name
+	}
+
+	if entry.Status == NotDeployed {
+		return formattedFileStatus
+	}
+
+	notes := []string{}
+	if len(entry.ConfigChanges) > 0 {
+		notes = append(notes, "config changed: "+strings.Join(entry.ConfigChanges, ", "))
+	}
+
+	// Drift is more pressing than the sync status, so its symbol takes
+	// precedence
+	if entry.Drift != NoDrift {
+		note := entry.Drift.String()
+		if entry.Drift == ForeignLink {
+			note += " to " + entry.DriftDetail
+		}
+		if entry.EffectiveStatus() == Unsynced {
+			note += ", pending sync"
+		}
+
+		formattedFileStatus = syms.Drift[entry.Drift] + " " + name + " <- " + entry.SymlinkPath
+		notes = append([]string{note}, notes...)
+	}
+
+	if len(notes) > 0 {
+		formattedFileStatus += " (" + strings.Join(notes, "; ") + ")"
 	}
 
 	return formattedFileStatus
 }
+
+// formatFileName shows alternates by the name they are deployed as, along with
+// the conditions that made them the chosen candidate.
+func formatFileName(name string) string {
+	if base, suffix, isAlternate := alternate.Split(name); isAlternate {
+		return base + " (" + alternate.Separator + suffix + ")"
+	}
+	return name
+}
@@ -0,0 +1,112 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mermonia/peridot/internal/hash"
+	"github.com/mermonia/peridot/internal/tree"
+)
+
+func TestGetModuleFileTree(t *testing.T) {
+	dir := t.TempDir()
+	moduleDir := filepath.Join(dir, "shell")
+
+	module := &ModuleState{
+		Status: Unsynced,
+		Files: map[string]*Entry{
+			filepath.Join(moduleDir, "zshrc"):          {Status: Synced, SymlinkPath: "/home/.zshrc"},
+			filepath.Join(moduleDir, "bashrc"):         {Status: Unsynced, SymlinkPath: "/home/.bashrc"},
+			filepath.Join(moduleDir, "config", "fish"): {Status: Synced, SymlinkPath: "/home/fish", Drift: LinkMissing},
+		},
+	}
+
+	tests := []struct {
+		opts     *TreeOptions
+		expected string
+	}{
+		{
+			opts: nil,
+			expected: "✗ shell - deployed, pending sync, 1 deployed file(s) drifted\n" +
+				"├── .\n" +
+				"│   ├── ✗ bashrc <- /home/.bashrc\n" +
+				"│   └── ✓ zshrc <- /home/.zshrc\n" +
+				"└── config\n" +
+				"    └── ∅ fish <- /home/fish (missing link)\n",
+		},
+		{
+			opts: &TreeOptions{Only: []string{FilterUnsynced}, Symbols: &ASCIIStatusSymbols},
+			expected: "x shell - deployed, pending sync, 1 deployed file(s) drifted\n" +
+				"└── .\n" +
+				"    └── x bashrc <- /home/.bashrc\n",
+		},
+	}
+
+	for _, test := range tests {
+		tr, err := GetModuleFileTree("shell", module, dir, test.opts)
+		if err != nil {
+			t.Fatalf("Could not get module file tree: %v", err)
+		}
+
+		var out strings.Builder
+		tree.PrintTree(tr, tree.DefaultTreeBranchSymbols, &out)
+		if out.String() != test.expected {
+			t.Fatalf("Expected the tree:\n%s\ngot:\n%s", test.expected, out.String())
+		}
+	}
+}
+
+func TestPartialChanges(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	hashOf := func(path string) string {
+		fileHash, err := hash.HashFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fileHash
+	}
+
+	colors, font := write("colors.tmpl", "#000"), write("font.tmpl", "Iosevka")
+	zshrc, bashrc, profile := write("zshrc", "zsh"), write("bashrc", "bash"), write("profile", "sh")
+
+	module := &ModuleState{
+		Status: Synced,
+		Files: map[string]*Entry{
+			zshrc:   {Status: Synced, SourceHash: hashOf(zshrc), Dependencies: map[string]string{colors: hashOf(colors)}},
+			bashrc:  {Status: Synced, SourceHash: hashOf(bashrc), Dependencies: map[string]string{font: hashOf(font)}},
+			profile: {Status: Synced, SourceHash: hashOf(profile)},
+		},
+	}
+	st := &State{Modules: map[string]*ModuleState{"shell": module}}
+
+	write("colors.tmpl", "#fff")
+	if err := st.updateDeploymentStatus(); err != nil {
+		t.Fatalf("Could not update the deployment status: %v", err)
+	}
+
+	if module.Files[zshrc].Status != Unsynced || module.Files[bashrc].Status != Synced || module.Files[profile].Status != Synced {
+		t.Fatalf("Expected only zshrc to be unsynced after editing its partial")
+	}
+
+	if err := os.Remove(font); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.updateDeploymentStatus(); err != nil {
+		t.Fatalf("Could not update the deployment status: %v", err)
+	}
+
+	if module.Files[bashrc].Status != Unsynced || module.Files[profile].Status != Synced {
+		t.Fatalf("Expected bashrc to be unsynced after removing its partial")
+	}
+}
@@ -0,0 +1,50 @@
+package state
+
+// StatusSymbols mark the status of modules and files in the status tree.
+type StatusSymbols struct {
+	Synced      string
+	Unsynced    string
+	NotDeployed string
+	Unknown     string
+	New         string
+	Ignored     string
+	Unmanaged   string
+	// Drift replaces the sync status symbol of drifted files
+	Drift map[Drift]string
+}
+
+var DefaultStatusSymbols StatusSymbols = StatusSymbols{
+	Synced:      "✓",
+	Unsynced:    "✗",
+	NotDeployed: "○",
+	Unknown:     "?",
+	New:         "+",
+	Ignored:     "·",
+	Unmanaged:   "◌",
+	Drift: map[Drift]string{
+		TargetModified:      "✎",
+		LinkMissing:         "∅",
+		ForeignLink:         "↪",
+		ReplacedByFile:      "≠",
+		IntermediateMissing: "⚠",
+	},
+}
+
+// ASCIIStatusSymbols are meant for terminals that cannot display the symbols
+// of DefaultStatusSymbols.
+var ASCIIStatusSymbols StatusSymbols = StatusSymbols{
+	Synced:      "v",
+	Unsynced:    "x",
+	NotDeployed: "o",
+	Unknown:     "?",
+	New:         "+",
+	Ignored:     "-",
+	Unmanaged:   "_",
+	Drift: map[Drift]string{
+		TargetModified:      "M",
+		LinkMissing:         "!",
+		ForeignLink:         ">",
+		ReplacedByFile:      "F",
+		IntermediateMissing: "I",
+	},
+}
@@ -0,0 +1,110 @@
+package sysinfo
+
+import (
+	"bufio"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	OSReleasePath = "/etc/os-release"
+	// ClassEnvName is the environment variable holding the comma-separated
+	// classes (or profiles, e.g. "work") the current machine belongs to.
+	ClassEnvName = "PERIDOT_CLASS"
+)
+
+// Info describes the machine peridot is currently running on. It is the
+// single source of truth for conditions and any other host-dependent
+// behavior, so that the same values are used everywhere.
+type Info struct {
+	OS         string
+	Arch       string
+	Hostname   string
+	Username   string
+	HomeDir    string
+	Shell      string
+	Distro     string
+	DistroLike []string
+	Classes    []string
+}
+
+var current *Info
+
+// Current returns the information of the running machine. It is only
+// gathered once per run.
+func Current() *Info {
+	if current == nil {
+		current = gather()
+	}
+
+	return current
+}
+
+func gather() *Info {
+	info := &Info{
+		OS:   runtime.GOOS,
+		Arch: runtime.GOARCH,
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		info.Hostname = hostname
+	}
+
+	if u, err := user.Current(); err == nil {
+		info.Username = u.Username
+	} else {
+		info.Username = os.Getenv("USER")
+	}
+
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		info.HomeDir = homeDir
+	}
+
+	if shell := os.Getenv("SHELL"); shell != "" {
+		info.Shell = filepath.Base(shell)
+	}
+
+	release := ReadOSRelease(OSReleasePath)
+	info.Distro = release["ID"]
+	info.DistroLike = strings.Fields(release["ID_LIKE"])
+
+	for _, class := range strings.Split(os.Getenv(ClassEnvName), ",") {
+		if class = strings.TrimSpace(class); class != "" {
+			info.Classes = append(info.Classes, class)
+		}
+	}
+
+	return info
+}
+
+// ReadOSRelease parses an os-release(5) file into a map. A missing or
+// unreadable file results in an empty map.
+func ReadOSRelease(path string) map[string]string {
+	release := map[string]string{}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return release
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+
+		release[key] = strings.Trim(value, `"'`)
+	}
+
+	return release
+}
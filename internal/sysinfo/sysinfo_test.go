@@ -0,0 +1,34 @@
+package sysinfo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadOSRelease(t *testing.T) {
+	dir := t.TempDir()
+
+	cases := map[string]map[string]string{
+		"NAME=\"Ubuntu\"\nID=ubuntu\nID_LIKE='debian'\n": {"NAME": "Ubuntu", "ID": "ubuntu", "ID_LIKE": "debian"},
+		"# comment\n\n  ID=\"arch\"  \nnot a pair\n":     {"ID": "arch"},
+		"NAME=\"Some Linux\"\nVERSION_ID=1\n":            {"NAME": "Some Linux", "VERSION_ID": "1"},
+		"":                                               {},
+	}
+
+	for content, expected := range cases {
+		path := filepath.Join(dir, "os-release")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if release := ReadOSRelease(path); !reflect.DeepEqual(release, expected) {
+			t.Fatalf("Expected %q to be parsed as %v, got %v", content, expected, release)
+		}
+	}
+
+	if release := ReadOSRelease(filepath.Join(dir, "missing")); len(release) != 0 {
+		t.Fatalf("Expected a missing file to be parsed as an empty map, got %v", release)
+	}
+}
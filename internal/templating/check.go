@@ -0,0 +1,239 @@
+package templating
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Issue is a problem found while checking a template.
+type Issue struct {
+	// Location is "file:line:col", or just a file name when unknown.
+	Location string
+	Message  string
+	// Warnings are reported, but do not make the check fail.
+	Warning bool
+}
+
+// CheckResult describes the outcome of checking a single template.
+type CheckResult struct {
+	Issues []Issue
+	// UsedVariables are the top-level variables the template refers to.
+	UsedVariables map[string]bool
+	// UsesAll is set when the template accesses the data as a whole (e.g.
+	// {{ toJson . }}), making it impossible to tell which variables it uses.
+	UsesAll bool
+}
+
+// Failed reports whether any of the issues is an error.
+func (r *CheckResult) Failed() bool {
+	for _, issue := range r.Issues {
+		if !issue.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// templateErrorRegexp splits errors from text/template, such as
+// "template: kitty.conf:3:12: executing ...", into location and message.
+var templateErrorRegexp = regexp.MustCompile(`^template: (.+?:\d+(?::\d+)?): (.*)$`)
+
+// Check parses the template at path, looks for references to undefined
+// variables, and renders it without writing the output anywhere. Undefined
+// variables are only errors in strict mode. Secrets are never looked up, and
+// render as empty strings.
+func Check(path string, opts *Options) *CheckResult {
+	result := &CheckResult{UsedVariables: map[string]bool{}}
+
+	if opts.Secrets != nil {
+		stubbed := *opts
+		stubbed.Secrets = opts.Secrets.Stub()
+		opts = &stubbed
+	}
+
+	t, _, err := parseTemplate(path, opts, &Result{Dependencies: []string{}})
+	if err != nil {
+		result.Issues = append(result.Issues, ErrorIssue(path, err))
+		return result
+	}
+
+	c := &checker{
+		t:       t,
+		data:    templateData(opts),
+		result:  result,
+		strict:  opts.Strict,
+		visited: map[string]bool{},
+	}
+	c.walkTemplate(t.Name())
+
+	// Undefined variables would be reported twice otherwise
+	if result.Failed() {
+		return result
+	}
+
+	if err := t.Execute(io.Discard, c.data); err != nil {
+		result.Issues = append(result.Issues, ErrorIssue(path, err))
+	}
+
+	return result
+}
+
+// ErrorIssue turns an error from parsing or rendering the template at path
+// into an issue, located at the position of the template it refers to.
+func ErrorIssue(path string, err error) Issue {
+	// Get rid of any wrapping done by peridot itself
+	for !strings.HasPrefix(err.Error(), "template: ") && errors.Unwrap(err) != nil {
+		err = errors.Unwrap(err)
+	}
+
+	if matches := templateErrorRegexp.FindStringSubmatch(err.Error()); matches != nil {
+		return Issue{Location: matches[1], Message: matches[2]}
+	}
+
+	return Issue{Location: path, Message: err.Error()}
+}
+
+type checker struct {
+	t       *template.Template
+	data    map[string]any
+	result  *CheckResult
+	strict  bool
+	visited map[string]bool
+}
+
+// walkTemplate walks a template whose dot is the root data, either the file
+// itself or a partial invoked as {{ template "name" . }}.
+func (c *checker) walkTemplate(name string) {
+	tmpl := c.t.Lookup(name)
+	if c.visited[name] || tmpl == nil || tmpl.Tree == nil {
+		return
+	}
+
+	c.visited[name] = true
+	c.walk(tmpl.Tree, tmpl.Tree.Root, true)
+}
+
+// walk visits every node, keeping track of whether dot still refers to the
+// root data, which is no longer the case inside range and with blocks.
+func (c *checker) walk(tree *parse.Tree, node parse.Node, atRoot bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			c.walk(tree, child, atRoot)
+		}
+	case *parse.ActionNode:
+		c.walkPipe(tree, n.Pipe, atRoot)
+	case *parse.IfNode:
+		c.walkPipe(tree, n.Pipe, atRoot)
+		c.walk(tree, n.List, atRoot)
+		c.walk(tree, n.ElseList, atRoot)
+	case *parse.RangeNode:
+		c.walkPipe(tree, n.Pipe, atRoot)
+		c.walk(tree, n.List, false)
+		c.walk(tree, n.ElseList, atRoot)
+	case *parse.WithNode:
+		c.walkPipe(tree, n.Pipe, atRoot)
+		c.walk(tree, n.List, false)
+		c.walk(tree, n.ElseList, atRoot)
+	case *parse.TemplateNode:
+		if n.Pipe != nil && atRoot && isDot(n.Pipe) {
+			c.walkTemplate(n.Name)
+			return
+		}
+		c.walkPipe(tree, n.Pipe, atRoot)
+	}
+}
+
+func (c *checker) walkPipe(tree *parse.Tree, pipe *parse.PipeNode, atRoot bool) {
+	if pipe == nil {
+		return
+	}
+
+	for _, cmd := range pipe.Cmds {
+		// {{ index . "name" }} is the way to read optional variables, so
+		// they are marked as used without being reported when undefined
+		if len(cmd.Args) >= 3 && atRoot && isIdentifier(cmd.Args[0], "index") {
+			if _, ok := cmd.Args[1].(*parse.DotNode); ok {
+				if s, ok := cmd.Args[2].(*parse.StringNode); ok {
+					c.result.UsedVariables[s.Text] = true
+					for _, arg := range cmd.Args[3:] {
+						c.walkArg(tree, arg, atRoot)
+					}
+					continue
+				}
+			}
+		}
+
+		for _, arg := range cmd.Args {
+			c.walkArg(tree, arg, atRoot)
+		}
+	}
+}
+
+func (c *checker) walkArg(tree *parse.Tree, arg parse.Node, atRoot bool) {
+	switch n := arg.(type) {
+	case *parse.FieldNode:
+		if atRoot {
+			c.reference(tree, n, n.Ident)
+		}
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			c.reference(tree, n, n.Ident[1:])
+		}
+	case *parse.DotNode:
+		if atRoot {
+			c.result.UsesAll = true
+		}
+	case *parse.ChainNode:
+		c.walkArg(tree, n.Node, atRoot)
+	case *parse.PipeNode:
+		c.walkPipe(tree, n, atRoot)
+	}
+}
+
+// reference records a reference to the variable at the given path, and
+// reports it if it is not defined.
+func (c *checker) reference(tree *parse.Tree, node parse.Node, path []string) {
+	c.result.UsedVariables[path[0]] = true
+
+	var current any = c.data
+	for i, key := range path {
+		table, ok := current.(map[string]any)
+		if !ok {
+			// Not a table, so the rest of the path is up to the value
+			return
+		}
+
+		if current, ok = table[key]; !ok {
+			location, _ := tree.ErrorContext(node)
+			c.result.Issues = append(c.result.Issues, Issue{
+				Location: location,
+				Message:  fmt.Sprintf("undefined variable .%s", strings.Join(path[:i+1], ".")),
+				Warning:  !c.strict,
+			})
+			return
+		}
+	}
+}
+
+func isDot(pipe *parse.PipeNode) bool {
+	if len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+
+	_, ok := pipe.Cmds[0].Args[0].(*parse.DotNode)
+	return ok
+}
+
+func isIdentifier(node parse.Node, name string) bool {
+	ident, ok := node.(*parse.IdentifierNode)
+	return ok && ident.Ident == name
+}
@@ -0,0 +1,65 @@
+package templating
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	variables := map[string]any{
+		"font":   "Iosevka",
+		"colors": map[string]any{"bg": "#000"},
+		"fonts":  []any{map[string]any{"name": "a"}},
+	}
+
+	tests := []struct {
+		content  string
+		strict   bool
+		issues   int
+		warnings int
+		used     []string
+		usesAll  bool
+	}{
+		{content: "{{ .font }} {{ .colors.bg }}", strict: true, used: []string{"font", "colors"}},
+		{content: "{{ .colors.fg }}", strict: true, issues: 1, used: []string{"colors"}},
+		{content: "{{ .missing }}", strict: false, issues: 1, warnings: 1},
+		{content: `{{ index . "missing" | default "x" }}`, strict: true, used: []string{"missing"}},
+		{content: "{{ range .fonts }}{{ .name }}{{ end }}", strict: true, used: []string{"fonts"}},
+		{content: "{{ range .fonts }}{{ $.font }}{{ end }}", strict: true, used: []string{"fonts", "font"}},
+		{content: "{{ toJson . }}", strict: true, usesAll: true},
+		{content: "{{ .font ", strict: true, issues: 1},
+	}
+
+	dir := t.TempDir()
+	for _, test := range tests {
+		path := filepath.Join(dir, "file")
+		if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		result := Check(path, &Options{ModuleDir: dir, Variables: variables, Strict: test.strict})
+
+		warnings := 0
+		for _, issue := range result.Issues {
+			if issue.Warning {
+				warnings++
+			}
+		}
+
+		if len(result.Issues) != test.issues || warnings != test.warnings {
+			t.Fatalf("Expected %d issue(s) and %d warning(s) for %q, got %v",
+				test.issues, test.warnings, test.content, result.Issues)
+		}
+
+		for _, name := range test.used {
+			if !result.UsedVariables[name] {
+				t.Fatalf("Expected %q to mark %s as used, got %v", test.content, name, result.UsedVariables)
+			}
+		}
+
+		if result.UsesAll != test.usesAll {
+			t.Fatalf("Expected UsesAll to be %t for %q", test.usesAll, test.content)
+		}
+	}
+}
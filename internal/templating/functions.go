@@ -0,0 +1,179 @@
+package templating
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mermonia/peridot/internal/paths"
+	"github.com/mermonia/peridot/internal/secrets"
+	"github.com/mermonia/peridot/internal/sysinfo"
+)
+
+// FuncMap returns the functions available to every template, on top of the
+// ones built into text/template. Files read by them are recorded as
+// dependencies in result. Keep `peridot help templates` in sync when adding
+// new ones.
+func FuncMap(opts *Options, result *Result) template.FuncMap {
+	system := opts.System
+	if system == nil {
+		system = sysinfo.Current()
+	}
+
+	return template.FuncMap{
+		"env":      os.Getenv,
+		"hostname": func() string { return system.Hostname },
+		"os":       func() string { return system.OS },
+		"arch":     func() string { return system.Arch },
+		"homeDir":  func() string { return system.HomeDir },
+		"xdg":      func(kind string) (string, error) { return xdgDir(kind, system.HomeDir) },
+		"lookPath": lookPath,
+		"exists": func(path string) bool {
+			return exists(resolve(path, opts.ModuleDir))
+		},
+		"default":  defaultValue,
+		"upper":    strings.ToUpper,
+		"lower":    strings.ToLower,
+		"replace":  func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"trim":     strings.TrimSpace,
+		"joinPath": filepath.Join,
+		"toJson":   toJson,
+		"toToml":   toToml,
+		"include": func(path string) (string, error) {
+			path = resolve(path, opts.ModuleDir)
+			if !slices.Contains(result.Dependencies, path) {
+				result.Dependencies = append(result.Dependencies, path)
+			}
+			return include(path)
+		},
+		"secret": func(args ...string) (string, error) {
+			result.Sensitive = true
+			return secret(opts.Secrets, args)
+		},
+	}
+}
+
+// secret implements {{ secret "name" }} and {{ secret "provider" "name" }}.
+func secret(store *secrets.Store, args []string) (string, error) {
+	if store == nil {
+		return "", fmt.Errorf("secrets are not available")
+	}
+
+	switch len(args) {
+	case 1:
+		return store.Get("", args[0])
+	case 2:
+		return store.Get(args[0], args[1])
+	default:
+		return "", fmt.Errorf("secret takes a name and, optionally, a provider before it")
+	}
+}
+
+var xdgDefaults = map[string]struct {
+	envName string
+	homeRel string
+}{
+	"config":  {"XDG_CONFIG_HOME", ".config"},
+	"data":    {"XDG_DATA_HOME", ".local/share"},
+	"state":   {"XDG_STATE_HOME", ".local/state"},
+	"cache":   {"XDG_CACHE_HOME", ".cache"},
+	"bin":     {"XDG_BIN_HOME", ".local/bin"},
+	"runtime": {"XDG_RUNTIME_DIR", ""},
+}
+
+func xdgDir(kind, homeDir string) (string, error) {
+	dir, ok := xdgDefaults[kind]
+	if !ok {
+		return "", fmt.Errorf("unknown xdg dir %q", kind)
+	}
+
+	if value := os.Getenv(dir.envName); value != "" {
+		return value, nil
+	}
+
+	if dir.homeRel == "" {
+		return "", fmt.Errorf("%s is not set", dir.envName)
+	}
+
+	return filepath.Join(homeDir, dir.homeRel), nil
+}
+
+func resolve(path, base string) string {
+	resolved, err := paths.ResolvePath(path, base)
+	if err != nil {
+		return path
+	}
+	return resolved
+}
+
+func lookPath(bin string) string {
+	path, err := exec.LookPath(bin)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// defaultValue returns value unless it is empty (as in a template
+// conditional), in which case it returns def. It is meant to be used as
+// {{ index . "font" | default "monospace" }}, as {{ .font }} fails in strict
+// templates when font is undefined.
+func defaultValue(def any, value ...any) any {
+	if len(value) == 0 || isEmpty(value[0]) {
+		return def
+	}
+	return value[0]
+}
+
+func isEmpty(value any) bool {
+	if value == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
+
+func toJson(value any) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("could not encode value as json: %w", err)
+	}
+	return string(encoded), nil
+}
+
+func toToml(value any) (string, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(value); err != nil {
+		return "", fmt.Errorf("could not encode value as toml: %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+func include(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("could not include file: %w", err)
+	}
+	return string(content), nil
+}
@@ -0,0 +1,134 @@
+package templating
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestXdgDir(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("XDG_RUNTIME_DIR", "")
+
+	tests := []struct {
+		kind     string
+		expected string
+		fails    bool
+	}{
+		{kind: "config", expected: "/xdg/config"},
+		{kind: "data", expected: "/home/me/.local/share"},
+		{kind: "bin", expected: "/home/me/.local/bin"},
+		{kind: "runtime", fails: true},
+		{kind: "music", fails: true},
+	}
+
+	for _, test := range tests {
+		dir, err := xdgDir(test.kind, "/home/me")
+		if (err != nil) != test.fails {
+			t.Fatalf("Expected xdg %q to fail: %t, got %v", test.kind, test.fails, err)
+		}
+		if dir != test.expected {
+			t.Fatalf("Expected xdg %q to be %q, got %q", test.kind, test.expected, dir)
+		}
+	}
+}
+
+func TestDefaultValue(t *testing.T) {
+	tests := []struct {
+		value    []any
+		expected any
+	}{
+		{nil, "x"},
+		{[]any{nil}, "x"},
+		{[]any{""}, "x"},
+		{[]any{0}, "x"},
+		{[]any{false}, "x"},
+		{[]any{[]any{}}, "x"},
+		{[]any{map[string]any{}}, "x"},
+		{[]any{(*int)(nil)}, "x"},
+		{[]any{"Iosevka"}, "Iosevka"},
+		{[]any{12}, 12},
+		{[]any{true}, true},
+	}
+
+	for _, test := range tests {
+		if value := defaultValue("x", test.value...); value != test.expected {
+			t.Fatalf("Expected default of %v to be %v, got %v", test.value, test.expected, value)
+		}
+	}
+}
+
+func TestEncodeFunctions(t *testing.T) {
+	value := map[string]any{"font": "Iosevka", "size": 12}
+
+	if encoded, err := toJson(value); err != nil || encoded != `{"font":"Iosevka","size":12}` {
+		t.Fatalf("Unexpected json encoding %q (%v)", encoded, err)
+	}
+
+	if encoded, err := toToml(value); err != nil || encoded != "font = \"Iosevka\"\nsize = 12" {
+		t.Fatalf("Unexpected toml encoding %q (%v)", encoded, err)
+	}
+
+	if _, err := toJson(func() {}); err == nil {
+		t.Fatalf("Expected an error when encoding a function as json")
+	}
+}
+
+func TestFileFunctions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "extra.conf"), []byte("{{ raw }}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	template := filepath.Join(dir, "file")
+	content := `{{ include "extra.conf" }}{{ exists "extra.conf" }} {{ exists "missing" }} {{ lookPath "peridot-missing-binary" }}`
+	if err := os.WriteFile(template, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	result, err := RenderFile(template, &Options{ModuleDir: dir, Strict: true}, &out)
+	if err != nil {
+		t.Fatalf("Could not render template: %v", err)
+	}
+
+	if expected := "{{ raw }}\ntrue false "; out.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, out.String())
+	}
+
+	if len(result.Dependencies) != 1 || result.Dependencies[0] != filepath.Join(dir, "extra.conf") {
+		t.Fatalf("Expected the included file to be a dependency, got %v", result.Dependencies)
+	}
+
+	if _, err := include(filepath.Join(dir, "missing")); err == nil {
+		t.Fatalf("Expected an error when including a missing file")
+	}
+
+	if lookPath("sh") == "" {
+		t.Fatalf("Expected sh to be found in PATH")
+	}
+}
+
+func TestStrictDefault(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{ModuleDir: dir, Variables: map[string]any{"font": "Iosevka"}, Strict: true}
+
+	tests := map[string]string{
+		`{{ index . "font" | default "monospace" }}`:    "Iosevka",
+		`{{ index . "missing" | default "monospace" }}`: "monospace",
+	}
+
+	for content, expected := range tests {
+		path := filepath.Join(dir, "file")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		if _, err := RenderFile(path, opts, &out); err != nil || out.String() != expected {
+			t.Fatalf("Expected %s to render %q, got %q (%v)", content, expected, out.String(), err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package templating
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// FindPartials lists the partial templates in the given dirs, in order, so
+// that partials in later dirs override those with the same name in earlier
+// ones. Missing dirs are skipped.
+func FindPartials(dirs ...string) ([]string, error) {
+	partials := []string{}
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("could not read partials dir %s: %w", dir, err)
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				partials = append(partials, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+
+	return partials, nil
+}
+
+// PartialName is the name a partial is invoked by, e.g. {{ template "colors" . }}
+// for a partial stored as colors.tmpl.
+func PartialName(path string) string {
+	name := filepath.Base(path)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// parsePartials adds every partial to the template set of t, each one named
+// after PartialName. It returns a map from template names to the partial
+// files defining them, including templates declared with {{ define }}
+// inside a partial.
+func parsePartials(t *template.Template, partials []string, leftDelim, rightDelim string) (map[string]string, error) {
+	definedBy := map[string]string{}
+
+	for _, path := range partials {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read partial: %w", err)
+		}
+
+		left, right := leftDelim, rightDelim
+		content, left, right = parseFrontMatter(content, left, right)
+
+		before := map[string]bool{}
+		for _, defined := range t.Templates() {
+			before[defined.Name()] = true
+		}
+
+		if _, err := t.New(PartialName(path)).Delims(left, right).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("could not parse partial %s: %w", path, err)
+		}
+
+		definedBy[PartialName(path)] = path
+		for _, defined := range t.Templates() {
+			if !before[defined.Name()] {
+				definedBy[defined.Name()] = path
+			}
+		}
+	}
+
+	return definedBy, nil
+}
+
+// referencedTemplates returns the names of every template invoked, directly
+// or through other templates, by the template named name.
+func referencedTemplates(t *template.Template, name string, seen map[string]bool) {
+	tmpl := t.Lookup(name)
+	if tmpl == nil || tmpl.Tree == nil {
+		return
+	}
+
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.IfNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			if !seen[n.Name] {
+				seen[n.Name] = true
+				referencedTemplates(t, n.Name, seen)
+			}
+		}
+	}
+
+	walk(tmpl.Tree.Root)
+}
@@ -0,0 +1,73 @@
+package templating
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestPartials(t *testing.T) {
+	dir := t.TempDir()
+	sharedDir := filepath.Join(dir, "_templates")
+	moduleDir := filepath.Join(dir, "shell")
+	partialsDir := filepath.Join(moduleDir, "_partials")
+
+	files := map[string]string{
+		filepath.Join(sharedDir, "colors.tmpl"):   "shared colors",
+		filepath.Join(sharedDir, "font.tmpl"):     "shared font",
+		filepath.Join(partialsDir, "colors.tmpl"): `module colors{{ define "accent" }}#111{{ end }}`,
+		filepath.Join(moduleDir, "zshrc"):         `{{ template "colors" . }}, {{ template "font" . }}, {{ template "accent" . }}`,
+		filepath.Join(moduleDir, "bashrc"):        `{{ template "font" . }}`,
+	}
+
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	partials, err := FindPartials(sharedDir, partialsDir, filepath.Join(dir, "missing"))
+	if err != nil {
+		t.Fatalf("Could not find partials: %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(sharedDir, "colors.tmpl"),
+		filepath.Join(sharedDir, "font.tmpl"),
+		filepath.Join(partialsDir, "colors.tmpl"),
+	}
+	if !slices.Equal(partials, expected) {
+		t.Fatalf("Expected partials %v, got %v", expected, partials)
+	}
+
+	tests := []struct {
+		file         string
+		rendered     string
+		dependencies []string
+	}{
+		{"zshrc", "module colors, shared font, #111", []string{expected[1], expected[2]}},
+		{"bashrc", "shared font", []string{expected[1]}},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		result, err := RenderFile(filepath.Join(moduleDir, test.file), &Options{ModuleDir: moduleDir, Partials: partials}, &out)
+		if err != nil {
+			t.Fatalf("Could not render %s: %v", test.file, err)
+		}
+
+		if out.String() != test.rendered {
+			t.Fatalf("Expected %s to render %q, got %q", test.file, test.rendered, out.String())
+		}
+
+		dependencies := slices.Sorted(slices.Values(result.Dependencies))
+		if !slices.Equal(dependencies, test.dependencies) {
+			t.Fatalf("Expected %s to depend on %v, got %v", test.file, test.dependencies, dependencies)
+		}
+	}
+}
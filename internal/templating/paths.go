@@ -0,0 +1,109 @@
+package templating
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// RenderPath renders the components of a path, relative to the module dir,
+// that contain template actions, as in ".config/{{ .peridot.hostname }}/x".
+// Every component must render to a valid, non-empty file name.
+func RenderPath(rel string, opts *Options) (string, error) {
+	parts := strings.Split(rel, string(filepath.Separator))
+	data := templateData(opts)
+
+	for i, part := range parts {
+		if !isTemplatedName(part, opts) {
+			continue
+		}
+
+		t, err := parseName(rel, part, opts)
+		if err != nil {
+			return "", err
+		}
+
+		var out strings.Builder
+		if err := t.Execute(&out, data); err != nil {
+			return "", fmt.Errorf("could not render file name: %w", err)
+		}
+
+		name := out.String()
+		if name == "" || name == "." || name == ".." || strings.ContainsRune(name, filepath.Separator) {
+			return "", fmt.Errorf("the name %q in %s renders to the invalid file name %q", part, rel, name)
+		}
+		parts[i] = name
+	}
+
+	return filepath.Join(parts...), nil
+}
+
+// CheckPath checks the templated components of a path just like Check does
+// with the contents of a file.
+func CheckPath(rel string, opts *Options) *CheckResult {
+	result := &CheckResult{UsedVariables: map[string]bool{}}
+
+	if opts.Secrets != nil {
+		stubbed := *opts
+		stubbed.Secrets = opts.Secrets.Stub()
+		opts = &stubbed
+	}
+
+	data := templateData(opts)
+
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if !isTemplatedName(part, opts) {
+			continue
+		}
+
+		t, err := parseName(rel, part, opts)
+		if err != nil {
+			result.Issues = append(result.Issues, ErrorIssue(rel, err))
+			continue
+		}
+
+		c := &checker{
+			t:       t,
+			data:    data,
+			result:  result,
+			strict:  opts.Strict,
+			visited: map[string]bool{},
+		}
+		c.walkTemplate(t.Name())
+	}
+
+	if result.Failed() {
+		return result
+	}
+
+	if _, err := RenderPath(rel, opts); err != nil {
+		result.Issues = append(result.Issues, ErrorIssue(rel, err))
+	}
+
+	return result
+}
+
+func isTemplatedName(name string, opts *Options) bool {
+	leftDelim := opts.LeftDelim
+	if leftDelim == "" {
+		leftDelim = "{{"
+	}
+
+	return strings.Contains(name, leftDelim)
+}
+
+// parseName parses a single component of a path as a template, named after
+// the whole path for error messages.
+func parseName(rel, name string, opts *Options) (*template.Template, error) {
+	t := template.New(rel).Funcs(FuncMap(opts, &Result{Dependencies: []string{}}))
+	if opts.Strict {
+		t.Option("missingkey=error")
+	}
+
+	if _, err := t.Delims(opts.LeftDelim, opts.RightDelim).Parse(name); err != nil {
+		return nil, fmt.Errorf("could not parse file name for templating: %w", err)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,33 @@
+package templating
+
+import "testing"
+
+func TestRenderPath(t *testing.T) {
+	opts := &Options{Variables: map[string]any{"app": "kitty", "empty": ""}, Strict: true}
+
+	tests := []struct {
+		rel      string
+		expected string
+		fails    bool
+	}{
+		{rel: ".config/{{ .app }}/{{ .app }}.conf", expected: ".config/kitty/kitty.conf"},
+		{rel: ".config/plain.conf", expected: ".config/plain.conf"},
+		{rel: "{{ .empty }}", fails: true},
+		{rel: `{{ "a/b" }}`, fails: true},
+		{rel: "{{ .missing }}.conf", fails: true},
+	}
+
+	for _, test := range tests {
+		rendered, err := RenderPath(test.rel, opts)
+		if test.fails {
+			if err == nil {
+				t.Fatalf("Expected %q to fail to render, got %q", test.rel, rendered)
+			}
+			continue
+		}
+
+		if err != nil || rendered != test.expected {
+			t.Fatalf("Expected %q to render to %q, got %q (%v)", test.rel, test.expected, rendered, err)
+		}
+	}
+}
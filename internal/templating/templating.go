@@ -1,45 +1,253 @@
 package templating
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"text/template"
 
 	"github.com/mermonia/peridot/internal/files"
+	"github.com/mermonia/peridot/internal/logger"
+	"github.com/mermonia/peridot/internal/secrets"
+	"github.com/mermonia/peridot/internal/sysinfo"
 	"github.com/mermonia/peridot/internal/utils"
 )
 
-func RenderFile(path string, variables map[string]string, out io.Writer) error {
+// ContextKey is the reserved variable under which peridot exposes built-in
+// data to every template, e.g. {{ .peridot.hostname }}.
+const ContextKey = "peridot"
+
+// Options holds everything, besides the file itself, that is needed to
+// render a template.
+type Options struct {
+	DotfilesDir string
+	Module      string
+	// ModuleDir is the dir relative paths in template functions such as
+	// include are resolved against.
+	ModuleDir string
+	// Root is the resolved root the module is deployed to.
+	Root string
+	// Target is the path the rendered file will be deployed at.
+	Target    string
+	Variables map[string]any
+	// System is the machine the template is rendered for. If nil, the
+	// current machine is used.
+	System *sysinfo.Info
+	// Delimiters of the template actions. Empty values fall back to the
+	// default {{ and }}.
+	LeftDelim  string
+	RightDelim string
+	// Partials are files parsed along with every template, so that they
+	// can be invoked by name (see PartialName).
+	Partials []string
+	// Strict makes rendering fail on references to undefined variables,
+	// instead of rendering them as "<no value>".
+	Strict bool
+	// Secrets resolves the secret function. If nil, using it is an error.
+	Secrets *secrets.Store
+}
+
+// Result describes a rendered template.
+type Result struct {
+	// Dependencies are the files, besides the template itself, whose
+	// contents were used to render it (partials and included files).
+	Dependencies []string
+	// Sensitive is set when the template uses secrets, in which case the
+	// rendered file must only be readable by its owner.
+	Sensitive bool
+}
+
+// delimsFrontMatter matches a first line such as "# peridot:delims [[ ]]",
+// which overrides the delimiters of a single file. The comment syntax does
+// not matter, as the whole line is removed before rendering. In scripts, it
+// goes right after the shebang instead.
+var delimsFrontMatter = regexp.MustCompile(`^.*peridot:delims\s+(\S+)\s+(\S+).*(\r?\n|$)`)
+
+func RenderFile(path string, opts *Options, out io.Writer) (*Result, error) {
+	result := &Result{Dependencies: []string{}}
+
 	if isTextFile, err := files.IsTextFile(path); err != nil {
-		return fmt.Errorf("could not check if file is text file: %w", err)
+		return nil, fmt.Errorf("could not check if file is text file: %w", err)
 	} else if !isTextFile {
-		return utils.CopyToWriter(path, out)
+		return result, utils.CopyToWriter(path, out)
+	}
+
+	t, partialFiles, err := parseTemplate(path, opts, result)
+	if err != nil {
+		return nil, err
+	}
+
+	used := map[string]bool{}
+	referencedTemplates(t, t.Name(), used)
+	for name := range used {
+		if partial, ok := partialFiles[name]; ok && !slices.Contains(result.Dependencies, partial) {
+			result.Dependencies = append(result.Dependencies, partial)
+		}
+	}
+
+	if err := t.Execute(out, templateData(opts)); err != nil {
+		return nil, err
 	}
 
-	t, err := template.ParseFiles(path)
+	return result, nil
+}
+
+// parseTemplate parses the file at path along with every partial. It returns
+// the template, named after the path relative to the module dir, and a map
+// from template names to the partial files defining them.
+func parseTemplate(path string, opts *Options, result *Result) (*template.Template, map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read template: %w", err)
+	}
+
+	name := filepath.Base(path)
+	if rel, err := filepath.Rel(opts.ModuleDir, path); err == nil && opts.ModuleDir != "" {
+		name = rel
+	}
+
+	t := template.New(name).Funcs(FuncMap(opts, result))
+	if opts.Strict {
+		t.Option("missingkey=error")
+	}
+
+	// Partials are parsed first, so that templates defined in the file
+	// itself take precedence over them
+	partialFiles, err := parsePartials(t, opts.Partials, opts.LeftDelim, opts.RightDelim)
 	if err != nil {
-		return fmt.Errorf("could not parse file for templating: %w", err)
+		return nil, nil, err
+	}
+
+	leftDelim, rightDelim := opts.LeftDelim, opts.RightDelim
+	content, leftDelim, rightDelim = parseFrontMatter(content, leftDelim, rightDelim)
+
+	if _, err := t.Delims(leftDelim, rightDelim).Parse(string(content)); err != nil {
+		return nil, nil, fmt.Errorf("could not parse file for templating: %w", err)
+	}
+
+	return t, partialFiles, nil
+}
+
+// templateData returns the module variables along with the built-in context
+// under ContextKey, which takes precedence over any variable of that name.
+func templateData(opts *Options) map[string]any {
+	system := opts.System
+	if system == nil {
+		system = sysinfo.Current()
+	}
+
+	data := make(map[string]any, len(opts.Variables)+1)
+	for k, v := range opts.Variables {
+		data[k] = v
+	}
+
+	if _, exists := data[ContextKey]; exists {
+		logger.Warn("The variable name is reserved and will be ignored", "variable", ContextKey)
+	}
+
+	data[ContextKey] = map[string]any{
+		"hostname":    system.Hostname,
+		"os":          system.OS,
+		"arch":        system.Arch,
+		"username":    system.Username,
+		"homeDir":     system.HomeDir,
+		"module":      opts.Module,
+		"moduleDir":   opts.ModuleDir,
+		"root":        opts.Root,
+		"target":      opts.Target,
+		"dotfilesDir": opts.DotfilesDir,
+	}
+
+	return data
+}
+
+// parseFrontMatter looks for the delimiters front matter in content, either
+// on the first line or on the one after a shebang, returning the delimiters
+// it specifies or, if absent, the given defaults.
+// The front matter is replaced by a template comment spanning the same
+// lines, so that it produces no output while line numbers in errors still
+// match the file.
+func parseFrontMatter(content []byte, leftDelim, rightDelim string) ([]byte, string, string) {
+	// The shebang must stay on the first line for the file to be runnable
+	start := 0
+	if bytes.HasPrefix(content, []byte("#!")) {
+		if i := bytes.IndexByte(content, '\n'); i >= 0 {
+			start = i + 1
+		}
+	}
+
+	line := content[start:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i+1]
 	}
 
-	return t.ExecuteTemplate(out, filepath.Base(path), variables)
+	matches := delimsFrontMatter.FindSubmatch(line)
+	if matches == nil {
+		return content, leftDelim, rightDelim
+	}
+
+	leftDelim, rightDelim = string(matches[1]), string(matches[2])
+	comment := leftDelim + "/*" + string(matches[3]) + "*/" + rightDelim
+
+	replaced := append([]byte{}, content[:start]...)
+	replaced = append(replaced, comment...)
+	return append(replaced, content[start+len(matches[0]):]...), leftDelim, rightDelim
 }
 
-func CreateRenderedFile(path, renderedFilePath string, variables map[string]string) error {
+// CreateRenderedFile renders the template at path into renderedFilePath. If
+// opts is nil, the file is not treated as a template and is copied verbatim.
+// Files rendered from templates that use secrets are created with mode 0600.
+func CreateRenderedFile(path, renderedFilePath string, opts *Options) (*Result, error) {
 	if err := os.MkdirAll(filepath.Dir(renderedFilePath), 0755); err != nil {
-		return fmt.Errorf("could not create parent dirs: %w", err)
+		return nil, fmt.Errorf("could not create parent dirs: %w", err)
+	}
+
+	if opts == nil {
+		out, err := os.OpenFile(renderedFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("could not create rendered file path: %w", err)
+		}
+		defer out.Close()
+
+		// The file might have been rendered from a template using secrets
+		if err := out.Chmod(0644); err != nil {
+			return nil, fmt.Errorf("could not set rendered file mode: %w", err)
+		}
+
+		return &Result{Dependencies: []string{}}, utils.CopyToWriter(path, out)
 	}
 
-	out, err := os.Create(renderedFilePath)
+	// The template is rendered in memory first, as the mode of the file
+	// depends on whether it uses secrets
+	var rendered bytes.Buffer
+	result, err := RenderFile(path, opts, &rendered)
 	if err != nil {
-		return fmt.Errorf("could not create rendered file path: %w", err)
+		return nil, fmt.Errorf("could not render template: %w", err)
+	}
+
+	var mode os.FileMode = 0644
+	if result.Sensitive {
+		mode = 0600
+	}
+
+	out, err := os.OpenFile(renderedFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return nil, fmt.Errorf("could not create rendered file path: %w", err)
 	}
 	defer out.Close()
 
-	if err := RenderFile(path, variables, out); err != nil {
-		return fmt.Errorf("could not render template: %w", err)
+	// The file might have existed with a different mode
+	if err := out.Chmod(mode); err != nil {
+		return nil, fmt.Errorf("could not set rendered file mode: %w", err)
+	}
+
+	if _, err := rendered.WriteTo(out); err != nil {
+		return nil, fmt.Errorf("could not write rendered file: %w", err)
 	}
 
-	return nil
+	return result, nil
 }
@@ -0,0 +1,95 @@
+package templating
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+		left     string
+		right    string
+	}{
+		{"# peridot:delims [[ ]]\nx=[[ .x ]]\n", "[[/*\n*/]]x=[[ .x ]]\n", "[[", "]]"},
+		{"// peridot:delims <% %> (jsonc)\r\nx\n", "<%/*\n*/%>x\n", "<%", "%>"},
+		{"#!/bin/sh\n# peridot:delims [[ ]]\necho\n", "#!/bin/sh\n[[/*\n*/]]echo\n", "[[", "]]"},
+		{"# peridot:delims [[ ]]", "[[/**/]]", "[[", "]]"},
+		{"x\n# peridot:delims [[ ]]\n", "x\n# peridot:delims [[ ]]\n", "<<", ">>"},
+		{"#!/bin/sh\necho\n# peridot:delims [[ ]]\n", "#!/bin/sh\necho\n# peridot:delims [[ ]]\n", "<<", ">>"},
+		{"# peridot:delims [[\n", "# peridot:delims [[\n", "<<", ">>"},
+	}
+
+	for _, test := range tests {
+		content, left, right := parseFrontMatter([]byte(test.content), "<<", ">>")
+		if string(content) != test.expected || left != test.left || right != test.right {
+			t.Fatalf("Expected %q to be parsed as %q with %s %s, got %q with %s %s",
+				test.content, test.expected, test.left, test.right, content, left, right)
+		}
+	}
+}
+
+func TestRenderDelims(t *testing.T) {
+	dir := t.TempDir()
+	variables := map[string]any{"font": "Iosevka"}
+
+	tests := []struct {
+		content  string
+		left     string
+		right    string
+		expected string
+	}{
+		{"font={{ .font }}\n", "", "", "font=Iosevka\n"},
+		{"font=<< .font >> {{ x }}\n", "<<", ">>", "font=Iosevka {{ x }}\n"},
+		{"# peridot:delims [[ ]]\nfont=[[ .font ]] << x >>\n", "<<", ">>", "font=Iosevka << x >>\n"},
+		{"#!/bin/sh\n# peridot:delims [[ ]]\necho [[ .font ]] {{ x }}\n", "", "", "#!/bin/sh\necho Iosevka {{ x }}\n"},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(dir, "file")
+		if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		opts := &Options{ModuleDir: dir, Variables: variables, LeftDelim: test.left, RightDelim: test.right, Strict: true}
+
+		var out bytes.Buffer
+		if _, err := RenderFile(path, opts, &out); err != nil {
+			t.Fatalf("Could not render %q: %v", test.content, err)
+		}
+		if out.String() != test.expected {
+			t.Fatalf("Expected %q to render %q, got %q", test.content, test.expected, out.String())
+		}
+	}
+}
+
+func TestCreateRenderedFileMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	if err := os.WriteFile(path, []byte("plain\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// As left behind by a previous version of the file that used secrets
+	rendered := filepath.Join(dir, "rendered")
+	for _, opts := range []*Options{nil, {ModuleDir: dir}} {
+		if err := os.WriteFile(rendered, []byte("secret\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := CreateRenderedFile(path, rendered, opts); err != nil {
+			t.Fatalf("Could not create rendered file: %v", err)
+		}
+
+		info, err := os.Stat(rendered)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0644 {
+			t.Fatalf("Expected the rendered file to have mode 0644, got %v", info.Mode().Perm())
+		}
+	}
+}
@@ -3,12 +3,15 @@ package tree
 import (
 	"fmt"
 	"io"
+	"slices"
 	"strings"
 )
 
 type Node struct {
 	Nodes []*Node
 	Value string
+	// Key is what the node is sorted by, instead of the value, if set
+	Key string
 }
 
 type TreeBranchSymbols struct {
@@ -25,6 +28,15 @@ var DefaultTreeBranchSymbols TreeBranchSymbols = TreeBranchSymbols{
 	Space:      "    ",
 }
 
+// ASCIITreeBranchSymbols are meant for terminals that cannot display the
+// box-drawing characters of DefaultTreeBranchSymbols.
+var ASCIITreeBranchSymbols TreeBranchSymbols = TreeBranchSymbols{
+	Branch:     "|-- ",
+	LastBranch: "`-- ",
+	Vertical:   "|   ",
+	Space:      "    ",
+}
+
 func PrintTree(root *Node, syms TreeBranchSymbols, out io.Writer) {
 	printBranch([]string{}, root, syms, out)
 }
@@ -86,7 +98,7 @@ func (r *Node) AddValue(value string) (*Node, error) {
 		return nil, fmt.Errorf("cannot add nodes to nil")
 	}
 
-	// append function. might sort it alphabetically later
+	// Nodes are kept in insertion order, see SortFunc
 	newNode := &Node{Value: value, Nodes: make([]*Node, 0)}
 	r.Nodes = append(r.Nodes, newNode)
 	return newNode, nil
@@ -101,7 +113,7 @@ func (r *Node) Add(node *Node) error {
 		return fmt.Errorf("cannot add nodes to nil")
 	}
 
-	// append function. might sort it alphabetically later
+	// Nodes are kept in insertion order, see SortFunc
 	r.Nodes = append(r.Nodes, node)
 	return nil
 }
@@ -148,3 +160,33 @@ func (r *Node) GetNodeByValueBFS(value string, maxDepth int) *Node {
 
 	return nil
 }
+
+// SortKey returns the key of the node, or its value if it has none.
+func (r *Node) SortKey() string {
+	if r.Key != "" {
+		return r.Key
+	}
+	return r.Value
+}
+
+// SortFunc sorts the children of the node, and theirs recursively, with the
+// given comparison function.
+func (r *Node) SortFunc(cmp func(a, b *Node) int) {
+	slices.SortStableFunc(r.Nodes, cmp)
+	for _, node := range r.Nodes {
+		node.SortFunc(cmp)
+	}
+}
+
+// Truncate removes the nodes that are more than depth levels below the node.
+// A depth of 0 leaves only the node itself.
+func (r *Node) Truncate(depth int) {
+	if depth <= 0 {
+		r.Nodes = make([]*Node, 0)
+		return
+	}
+
+	for _, node := range r.Nodes {
+		node.Truncate(depth - 1)
+	}
+}
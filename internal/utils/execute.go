@@ -25,3 +25,14 @@ func ExecHook(hook string) error {
 	fmt.Print(string(output))
 	return nil
 }
+
+// CommandSucceeds runs the given command without a shell and reports whether
+// it exited with a zero status. Its output is discarded.
+func CommandSucceeds(command string) bool {
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return false
+	}
+
+	return exec.Command(parts[0], parts[1:]...).Run() == nil
+}
@@ -0,0 +1,139 @@
+package vars
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFile reads the variables in a data file, whose format is chosen by its
+// extension: JSON (.json), TOML (.toml), YAML (.yaml, .yml) or dotenv (.env,
+// or a file named .env). The top level of the file must be a table.
+func LoadFile(path string) (map[string]any, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read vars file: %w", err)
+	}
+
+	variables := map[string]any{}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); {
+	case ext == ".json":
+		err = decodeJSON(content, &variables)
+	case ext == ".toml":
+		_, err = toml.Decode(string(content), &variables)
+	case ext == ".yaml" || ext == ".yml":
+		err = yaml.Unmarshal(content, &variables)
+	case ext == ".env":
+		variables, err = parseDotenv(content)
+	default:
+		return nil, fmt.Errorf("unsupported format of vars file %s, must be json, toml, yaml or env", path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not decode vars file %s: %w", path, err)
+	}
+
+	return normalize(variables).(map[string]any), nil
+}
+
+// decodeJSON decodes numbers as json.Number rather than float64, so that
+// normalize can tell integers apart.
+func decodeJSON(content []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("unexpected data after the top-level value")
+	}
+	return nil
+}
+
+// normalize converts the values decoded from JSON and YAML into the types
+// the TOML decoder produces, so that the format of a vars file does not
+// matter: tables whose keys might not be strings become map[string]any, and
+// whole numbers become int64.
+func normalize(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case int:
+		return int64(v)
+	case map[string]any:
+		for k, elem := range v {
+			v[k] = normalize(elem)
+		}
+		return v
+	case map[any]any:
+		table := make(map[string]any, len(v))
+		for k, elem := range v {
+			table[fmt.Sprint(k)] = normalize(elem)
+		}
+		return table
+	case []any:
+		for i, elem := range v {
+			v[i] = normalize(elem)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// parseDotenv parses KEY=value lines. Blank lines, comments and a leading
+// "export" are ignored, and values may be single or double quoted. Every
+// value is a string.
+func parseDotenv(content []byte) (map[string]any, error) {
+	variables := map[string]any{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=value", lineNumber)
+		}
+
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid quoted value: %w", lineNumber, err)
+			}
+			value = unquoted
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+
+		variables[key] = value
+	}
+
+	return variables, scanner.Err()
+}
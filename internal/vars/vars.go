@@ -0,0 +1,179 @@
+package vars
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mermonia/peridot/internal/config"
+	"github.com/mermonia/peridot/internal/paths"
+)
+
+// Names of the layers variables can come from, as shown by `peridot vars`.
+const (
+	GlobalSource = paths.GlobalConfigFileName
+	ModuleSource = paths.ModuleConfigFileName
+	CLISource    = "--var"
+)
+
+// Layer is a set of variables coming from a single source.
+type Layer struct {
+	Source    string
+	Variables map[string]any
+}
+
+// Set is the result of merging several layers. Sources maps the dotted path of
+// every leaf value (e.g. "colors.accent") to the layer it was taken from.
+type Set struct {
+	Values  map[string]any
+	Sources map[string]string
+}
+
+// Resolve merges every variable layer that applies to a module, in increasing
+// order of precedence:
+//
+//  1. [variables] in peridot.toml
+//  2. [variables] in hosts/<hostname>.toml
+//  3. The module's layers: its vars_files and [variables] in module.toml
+//  4. --var key=value overrides
+//
+// Tables are merged recursively, so a layer can override a single key of a
+// table defined by a previous one. Any other value is replaced as a whole.
+func Resolve(dotfilesDir, hostname string, moduleLayers []Layer, overrides map[string]any) (*Set, error) {
+	globalCfg, err := config.Load(dotfilesDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not load global config: %w", err)
+	}
+
+	hostCfg, err := config.LoadHost(dotfilesDir, hostname)
+	if err != nil {
+		return nil, fmt.Errorf("could not load host config: %w", err)
+	}
+
+	layers := []Layer{
+		{Source: GlobalSource, Variables: globalCfg.Variables},
+		{Source: HostSource(hostname), Variables: hostCfg.Variables},
+	}
+	layers = append(layers, moduleLayers...)
+	layers = append(layers, Layer{Source: CLISource, Variables: overrides})
+
+	return Merge(layers...), nil
+}
+
+func HostSource(hostname string) string {
+	return filepath.Join(paths.HostsDirName, hostname+".toml")
+}
+
+func Merge(layers ...Layer) *Set {
+	set := &Set{
+		Values:  map[string]any{},
+		Sources: map[string]string{},
+	}
+
+	for _, layer := range layers {
+		mergeInto(set, set.Values, layer.Variables, layer.Source, "")
+	}
+
+	return set
+}
+
+func mergeInto(set *Set, dst, src map[string]any, source, prefix string) {
+	for key, value := range src {
+		path := prefix + key
+
+		srcTable, srcIsTable := value.(map[string]any)
+		dstTable, dstIsTable := dst[key].(map[string]any)
+
+		if srcIsTable && dstIsTable {
+			mergeInto(set, dstTable, srcTable, source, path+".")
+			continue
+		}
+
+		// The value replaces whatever was there, including any leaves
+		// of a table previously found at the same path
+		delete(set.Sources, path)
+		for leaf := range set.Sources {
+			if strings.HasPrefix(leaf, path+".") {
+				delete(set.Sources, leaf)
+			}
+		}
+
+		if srcIsTable {
+			dst[key] = map[string]any{}
+			mergeInto(set, dst[key].(map[string]any), srcTable, source, path+".")
+			continue
+		}
+
+		dst[key] = value
+		set.Sources[path] = source
+	}
+}
+
+// Leaves returns the dotted paths of every leaf value in the set, sorted.
+func (s *Set) Leaves() []string {
+	return slices.Sorted(maps.Keys(s.Sources))
+}
+
+// Lookup returns the value at a dotted path, such as "colors.accent".
+func (s *Set) Lookup(path string) (any, bool) {
+	var current any = s.Values
+
+	for _, key := range strings.Split(path, ".") {
+		table, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		if current, ok = table[key]; !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// ParseAssignments parses key=value pairs, as passed to --var, into a
+// variable map. Dotted keys create nested tables, and values are decoded as
+// TOML values when possible (e.g. true, 42, ["a", "b"]) or kept as plain
+// strings otherwise.
+func ParseAssignments(assignments []string) (map[string]any, error) {
+	variables := map[string]any{}
+
+	for _, assignment := range assignments {
+		key, value, found := strings.Cut(assignment, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid variable assignment %q, expected key=value", assignment)
+		}
+
+		table := variables
+		parts := strings.Split(key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := table[part].(map[string]any)
+			if !ok {
+				next = map[string]any{}
+				table[part] = next
+			}
+			table = next
+		}
+
+		table[parts[len(parts)-1]] = parseValue(value)
+	}
+
+	return variables, nil
+}
+
+func parseValue(value string) any {
+	var decoded struct {
+		V any `toml:"v"`
+	}
+
+	if _, err := toml.Decode("v = "+value, &decoded); err == nil && decoded.V != nil {
+		return decoded.V
+	}
+
+	return value
+}
@@ -0,0 +1,122 @@
+package vars
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	set := Merge(
+		Layer{Source: "global", Variables: map[string]any{
+			"email":  "me@example.com",
+			"font":   "Iosevka",
+			"colors": map[string]any{"bg": "#000", "accent": "#111"},
+			"fonts":  []any{"a", "b"},
+		}},
+		Layer{Source: "host", Variables: map[string]any{
+			"colors": map[string]any{"accent": "#222"},
+			"fonts":  []any{"c"},
+		}},
+		Layer{Source: "module", Variables: map[string]any{
+			"font":  "Fira",
+			"email": map[string]any{"work": "me@work.com"},
+		}},
+	)
+
+	expectedSources := map[string]string{
+		"email.work":    "module",
+		"font":          "module",
+		"colors.bg":     "global",
+		"colors.accent": "host",
+		"fonts":         "host",
+	}
+
+	if !reflect.DeepEqual(set.Sources, expectedSources) {
+		t.Fatalf("Expected sources %v, got %v", expectedSources, set.Sources)
+	}
+
+	if value, _ := set.Lookup("colors.accent"); value != "#222" {
+		t.Fatalf("Expected colors.accent to be overridden by the host layer, got %v", value)
+	}
+
+	if value, _ := set.Lookup("fonts"); !reflect.DeepEqual(value, []any{"c"}) {
+		t.Fatalf("Expected lists to be replaced as a whole, got %v", value)
+	}
+}
+
+func TestParseAssignments(t *testing.T) {
+	variables, err := ParseAssignments([]string{
+		"font=Iosevka Nerd Font",
+		"enable_gpu=true",
+		"size=12",
+		"colors.accent=#ff0000",
+		"mode=0755",
+	})
+	if err != nil {
+		t.Fatalf("Could not parse assignments: %v", err)
+	}
+
+	expected := map[string]any{
+		"font":       "Iosevka Nerd Font",
+		"enable_gpu": true,
+		"size":       int64(12),
+		"colors":     map[string]any{"accent": "#ff0000"},
+		"mode":       "0755",
+	}
+
+	if !reflect.DeepEqual(variables, expected) {
+		t.Fatalf("Expected %v, got %v", expected, variables)
+	}
+
+	if _, err := ParseAssignments([]string{"novalue"}); err == nil {
+		t.Fatalf("Expected an error for an assignment without a value")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"palette.json": `{"colors": {"bg": "#000"}, "size": 12, "scale": 1.5, "fonts": ["a", 2]}`,
+		"palette.toml": "size = 12\nscale = 1.5\nfonts = [\"a\", 2]\n[colors]\nbg = \"#000\"\n",
+		"palette.yaml": "colors:\n  bg: \"#000\"\nsize: 12\nscale: 1.5\nfonts: [a, 2]\n",
+		".env":         "# comment\nexport API_URL=\"https://example.com\"\nUSER=me # trailing comment\nQUOTED='a b'\n",
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Every format must decode to the same values, with the same types
+	palette := map[string]any{
+		"colors": map[string]any{"bg": "#000"},
+		"size":   int64(12),
+		"scale":  1.5,
+		"fonts":  []any{"a", int64(2)},
+	}
+
+	for _, name := range []string{"palette.json", "palette.toml", "palette.yaml"} {
+		variables, err := LoadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Could not load %s: %v", name, err)
+		}
+
+		if !reflect.DeepEqual(variables, palette) {
+			t.Fatalf("Expected %s to contain %#v, got %#v", name, palette, variables)
+		}
+	}
+
+	variables, err := LoadFile(filepath.Join(dir, ".env"))
+	if err != nil {
+		t.Fatalf("Could not load .env: %v", err)
+	}
+
+	expected := map[string]any{"API_URL": "https://example.com", "USER": "me", "QUOTED": "a b"}
+	if !reflect.DeepEqual(variables, expected) {
+		t.Fatalf("Expected %v, got %v", expected, variables)
+	}
+}